@@ -0,0 +1,166 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// bulkChunk 分块写入的一块，offset 为第一个操作在 models 中的下标
+type bulkChunk struct {
+	offset int
+	models []mongo.WriteModel
+	done   bool
+	ret    *mongo.BulkWriteResult
+	err    error
+}
+
+// split 按 chunkSize 分块
+func (bwm *BulkWriteModel) split(models []mongo.WriteModel) []*bulkChunk {
+	size := bwm.chunkSize
+	if size <= 0 || size > len(models) {
+		size = len(models)
+	}
+	chunks := make([]*bulkChunk, 0, (len(models)+size-1)/size)
+	for i := 0; i < len(models); i += size {
+		end := i + size
+		if end > len(models) {
+			end = len(models)
+		}
+		chunks = append(chunks, &bulkChunk{offset: i, models: models[i:end]})
+	}
+	return chunks
+}
+
+func (bwm *BulkWriteModel) isOrdered() bool {
+	return bwm.ordered == nil || *bwm.ordered
+}
+
+// writeChunks 写入所有块：ordered 时出现错误后停止；unordered 时只有非写入错误（如：网络错误）才停止
+// unordered 且 concurrency 大于 1 时并发写入，事务中的 session 不能并发使用，按顺序写入
+func (bwm *BulkWriteModel) writeChunks(ctx context.Context, coll *mongo.Collection,
+	chunks []*bulkChunk, opts *options.BulkWriteOptions) {
+	write := func(ch *bulkChunk) {
+		ch.ret, ch.err = coll.BulkWrite(ctx, ch.models, opts)
+		ch.done = true
+	}
+
+	ordered := bwm.isOrdered()
+	if !ordered && bwm.concurrency > 1 && len(chunks) > 1 && mongo.SessionFromContext(ctx) == nil {
+		sem := make(chan struct{}, bwm.concurrency)
+		var wg sync.WaitGroup
+		for _, ch := range chunks {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(ch *bulkChunk) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				write(ch)
+			}(ch)
+		}
+		wg.Wait()
+		return
+	}
+
+	for _, ch := range chunks {
+		write(ch)
+		if ch.err == nil {
+			continue
+		}
+		var bwe mongo.BulkWriteException
+		if ordered || !errors.As(ch.err, &bwe) || len(bwe.WriteErrors) <= 0 {
+			return
+		}
+	}
+}
+
+// merge 汇总所有块的结果，失败的操作下标为在 BulkWriteModel 中的下标
+// 存在写入错误时返回 *ErrBulkPartial，其他错误原样返回，同时返回已执行部分的结果
+func (bwm *BulkWriteModel) merge(chunks []*bulkChunk) (*BulkWriteResult, error) {
+	ret := &BulkWriteResult{UpsertedIDs: map[int64]interface{}{}}
+	var (
+		fatal       error
+		writeErrors []mongo.BulkWriteError
+		wce         *mongo.WriteConcernError
+		labels      []string
+	)
+	// skip 结果不确定的操作：未执行、失败，以及 ordered 时第一个失败之后的操作
+	skip := map[int]bool{}
+	cutoff := -1
+	for _, ch := range chunks {
+		if !ch.done || (ch.err != nil && ch.ret == nil) {
+			for i := range ch.models {
+				skip[ch.offset+i] = true
+			}
+		}
+		if ch.ret != nil {
+			ret.InsertedCount += ch.ret.InsertedCount
+			ret.MatchedCount += ch.ret.MatchedCount
+			ret.ModifiedCount += ch.ret.ModifiedCount
+			ret.DeletedCount += ch.ret.DeletedCount
+			ret.UpsertedCount += ch.ret.UpsertedCount
+			for idx, id := range ch.ret.UpsertedIDs {
+				ret.UpsertedIDs[idx+int64(ch.offset)] = id
+			}
+		}
+		if ch.err == nil {
+			continue
+		}
+
+		var bwe mongo.BulkWriteException
+		if !errors.As(ch.err, &bwe) {
+			for i := range ch.models {
+				skip[ch.offset+i] = true
+			}
+			if fatal == nil {
+				fatal = ch.err
+			}
+			continue
+		}
+		for _, we := range bwe.WriteErrors {
+			we.Index += ch.offset
+			writeErrors = append(writeErrors, we)
+			skip[we.Index] = true
+			if cutoff < 0 || we.Index < cutoff {
+				cutoff = we.Index
+			}
+			ret.Failures = append(ret.Failures, BulkFailure{
+				Index:   we.Index,
+				Code:    we.Code,
+				Message: we.Message,
+				Err:     wrapWriteError(we.WriteError),
+				Model:   bwm.models[we.Index],
+			})
+		}
+		if bwe.WriteConcernError != nil && wce == nil {
+			wce = bwe.WriteConcernError
+		}
+		labels = append(labels, bwe.Labels...)
+	}
+
+	outcomes := bwm.upsertOutcomes(ret.UpsertedIDs)
+	for _, o := range outcomes {
+		if skip[o.Index] || (bwm.isOrdered() && cutoff >= 0 && o.Index > cutoff) {
+			continue
+		}
+		ret.Upserts = append(ret.Upserts, o)
+	}
+
+	if fatal != nil {
+		return ret, fatal
+	}
+	if len(writeErrors) <= 0 && wce == nil {
+		return ret, nil
+	}
+	bwe := mongo.BulkWriteException{WriteConcernError: wce, WriteErrors: writeErrors, Labels: labels}
+	if len(ret.Failures) <= 0 {
+		return ret, bwe
+	}
+	return ret, &ErrBulkPartial{Failures: ret.Failures, Err: bwe}
+}
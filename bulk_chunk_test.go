@@ -0,0 +1,55 @@
+package mongo
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestBulkChunkMerge(t *testing.T) {
+	bwm := NewBulkWriteModel().SetOrdered(false).SetChunkSize(2)
+	for i := 0; i < 5; i++ {
+		bwm.AddUpsertBy([]string{"code"}, map[string]interface{}{"code": i, "name": "a"})
+	}
+	chunks := bwm.split(bwm.models)
+	if len(chunks) != 3 || chunks[2].offset != 4 || len(chunks[2].models) != 1 {
+		t.Fatal("split", len(chunks))
+	}
+
+	id := NewObjectID()
+	chunks[0].done, chunks[0].ret = true, &mongo.BulkWriteResult{MatchedCount: 2}
+	chunks[1].done, chunks[1].ret = true, &mongo.BulkWriteResult{UpsertedCount: 1, UpsertedIDs: map[int64]interface{}{0: id}}
+	chunks[1].err = mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{
+		WriteError: mongo.WriteError{Index: 1, Code: codeDuplicateKey, Message: "E11000 duplicate key error"},
+	}}}
+	chunks[2].done, chunks[2].ret = true, &mongo.BulkWriteResult{MatchedCount: 1}
+
+	ret, err := bwm.merge(chunks)
+	var bulk *ErrBulkPartial
+	if !errors.As(err, &bulk) || len(bulk.Failures) != 1 || !IsDuplicateKey(err) {
+		t.Fatal("merge error", err)
+	}
+	f := ret.Failures[0]
+	if f.Index != 3 || f.Code != codeDuplicateKey || f.Model != bwm.models[3] {
+		t.Fatal("failure", f)
+	}
+	if ret.MatchedCount != 3 || ret.UpsertedCount != 1 || ret.UpsertedIDs[2] != id {
+		t.Fatal("counts", ret)
+	}
+	if len(ret.Upserts) != 4 || !ret.Upserts[2].Inserted || ret.Upserts[2].Index != 2 || ret.Upserts[3].Index != 4 {
+		t.Fatal("upserts", ret.Upserts)
+	}
+
+	// ordered：失败的块之后不再写入
+	bwm.SetOrdered(true)
+	chunks = bwm.split(bwm.models)
+	chunks[0].done, chunks[0].ret = true, &mongo.BulkWriteResult{MatchedCount: 1}
+	chunks[0].err = mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{
+		WriteError: mongo.WriteError{Index: 0, Code: codeDuplicateKey},
+	}}}
+	ret, err = bwm.merge(chunks)
+	if !errors.As(err, &bulk) || ret.Failures[0].Index != 0 || len(ret.Upserts) != 0 {
+		t.Fatal("ordered", err, ret.Upserts)
+	}
+}
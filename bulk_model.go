@@ -8,8 +8,18 @@ import (
 
 // BulkWriteModel 批量写入模型
 type BulkWriteModel struct {
+	BasicWriteOptions
+
 	models  []mongo.WriteModel
 	ordered *bool
+	// upserts AddUpsertBy 添加的操作下标
+	upserts []int
+	// chunkSize 每次写入的操作数量，0 为不分块
+	chunkSize int
+	// concurrency unordered 时同时写入的块数量
+	concurrency int
+	// keyType 表的主键类型，nil 时 _id 原样写入
+	keyType *KeyType
 }
 
 // NewBulkWriteModel 创建批量写入模型
@@ -59,6 +69,8 @@ func (bwm *BulkWriteModel) AddDeleteManyModel(filter *Query) *BulkWriteModel {
 	return bwm
 }
 
+// AddInsertOneModel 添加插入操作，doc 为 map 或 struct
+// 设置了 SetKeyType 时按主键类型处理 _id，类型不符时 panic；未设置时只删除空字符串 _id，ORM.BulkWrite 写入时按表的主键类型处理
 func (bwm *BulkWriteModel) AddInsertOneModel(doc interface{}) *BulkWriteModel {
 	var m map[string]interface{}
 	switch doc := doc.(type) {
@@ -68,15 +80,12 @@ func (bwm *BulkWriteModel) AddInsertOneModel(doc interface{}) *BulkWriteModel {
 		m = Struct2Map(doc)
 	}
 
-	if id, ok := m["_id"]; ok {
-		switch id := id.(type) {
-		case string:
-			if id == "" {
-				delete(m, "_id")
-			} else {
-				m["_id"] = TryString2ObjectID(id)
-			}
+	if bwm.keyType != nil {
+		if err := bwm.keyType.formatInsertID(m); err != nil {
+			panic(err)
 		}
+	} else if id, ok := m["_id"]; ok && id == "" {
+		delete(m, "_id")
 	}
 
 	delModel := mongo.NewInsertOneModel().SetDocument(m)
@@ -90,11 +99,67 @@ func (bwm *BulkWriteModel) AddNewReplaceOneModel(filter *Query, replaceDoc map[s
 	return bwm
 }
 
+// SetKeyType 设置表的主键类型，之后 AddInsertOneModel、AddUpsertBy 添加的文档按主键类型处理 _id
+func (bwm *BulkWriteModel) SetKeyType(kt KeyType) *BulkWriteModel {
+	bwm.keyType = &kt
+	return bwm
+}
+
+// withKeyType 未设置主键类型时，返回按 kt 处理插入文档 _id 的副本，不修改原文档
+func (bwm *BulkWriteModel) withKeyType(kt KeyType) (*BulkWriteModel, error) {
+	if bwm.keyType != nil {
+		return bwm, nil
+	}
+
+	cp := *bwm
+	cp.keyType = &kt
+	cp.models = make([]mongo.WriteModel, len(bwm.models))
+	for i, model := range bwm.models {
+		cp.models[i] = model
+		insert, ok := model.(*mongo.InsertOneModel)
+		if !ok {
+			continue
+		}
+		doc, ok := insert.Document.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		m := make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			m[k] = v
+		}
+		if err := kt.formatInsertID(m); err != nil {
+			return nil, err
+		}
+		cp.models[i] = mongo.NewInsertOneModel().SetDocument(m)
+	}
+	return &cp, nil
+}
+
+// WriteConcern 设置批量写入的写关注，如：WriteConcern{W: "majority", J: true}
+func (bwm *BulkWriteModel) WriteConcern(wc WriteConcern) *BulkWriteModel {
+	bwm.BasicWriteOptions.WriteConcern(wc)
+	return bwm
+}
+
 func (bwm *BulkWriteModel) SetOrdered(ordered bool) *BulkWriteModel {
 	bwm.ordered = &ordered
 	return bwm
 }
 
+// SetChunkSize 按 size 个操作分块写入，小于等于 0 为不分块（由driver按服务端限制拆分）
+// ordered 时按顺序写入，某一块出现错误后停止；unordered 时所有块都会写入
+func (bwm *BulkWriteModel) SetChunkSize(size int) *BulkWriteModel {
+	bwm.chunkSize = size
+	return bwm
+}
+
+// SetConcurrency 分块写入时同时写入的块数量，只对 unordered 生效，事务中按顺序写入
+func (bwm *BulkWriteModel) SetConcurrency(n int) *BulkWriteModel {
+	bwm.concurrency = n
+	return bwm
+}
+
 func (bwm *BulkWriteModel) Empty() bool {
 	return len(bwm.models) <= 0
 }
@@ -0,0 +1,254 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrBulkWriterClosed BulkWriter 已关闭
+var ErrBulkWriterClosed = errors.New("[mongo]: bulk writer is closed")
+
+// BulkWriterOptions BulkWriter 配置
+type BulkWriterOptions struct {
+	// MaxOps 达到该数量时写入，默认 1000
+	MaxOps int
+	// FlushInterval 定时写入的间隔，默认 1s
+	FlushInterval time.Duration
+	// QueueSize 等待写入的操作数量上限，队列满时 Add 阻塞，默认 MaxOps 的 2 倍
+	QueueSize int
+	// Ordered 按顺序写入，默认 unordered
+	Ordered bool
+	// WriteConcern 写关注，nil 使用集合的配置
+	WriteConcern *WriteConcern
+	// OnError 写入失败时调用，ret 为已执行部分的结果，在写入的 goroutine 中执行
+	OnError func(err error, ret *BulkWriteResult)
+	// ErrorBuffer Errors 通道的缓冲大小，默认 16，通道满时丢弃错误
+	ErrorBuffer int
+}
+
+// BulkWriter 后台批量写入，多个 goroutine 可以同时 Add，达到数量或定时写入
+type BulkWriter struct {
+	coll *Collection
+	opts BulkWriterOptions
+	// ctx 后台写入使用的 ctx，为集合的 ctx 去掉 session
+	ctx context.Context
+
+	mu     sync.RWMutex
+	closed bool
+	// adding 正在执行的 Add，关闭时等待结束后再写入剩余的操作
+	adding sync.WaitGroup
+
+	queue   chan mongo.WriteModel
+	flushCh chan chan error
+	closing chan struct{}
+	done    chan struct{}
+	errs    chan error
+	// closeErr 关闭时最后一次写入的错误
+	closeErr error
+}
+
+// NewBulkWriter 创建绑定集合的 BulkWriter，启动后台写入，使用完需要 Close
+// 后台写入不使用集合 ctx 中的 session（不在事务中执行），租户等其他值保留
+func (c *Collection) NewBulkWriter(opts *BulkWriterOptions) *BulkWriter {
+	w := &BulkWriter{coll: c, ctx: detachSession(c.ctx)}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.MaxOps <= 0 {
+		w.opts.MaxOps = 1000
+	}
+	if w.opts.FlushInterval <= 0 {
+		w.opts.FlushInterval = time.Second
+	}
+	if w.opts.QueueSize <= 0 {
+		w.opts.QueueSize = w.opts.MaxOps * 2
+	}
+	if w.opts.ErrorBuffer <= 0 {
+		w.opts.ErrorBuffer = 16
+	}
+
+	w.queue = make(chan mongo.WriteModel, w.opts.QueueSize)
+	w.flushCh = make(chan chan error)
+	w.closing = make(chan struct{})
+	w.done = make(chan struct{})
+	w.errs = make(chan error, w.opts.ErrorBuffer)
+	go w.run()
+	return w
+}
+
+// Add 添加 bwm 中的所有操作，使用 BulkWriteModel 的 AddXxx 构造，bwm 的写关注、ordered 等配置不生效
+// 队列满时阻塞，直到有空间、ctx 结束或 BulkWriter 关闭，关闭时已加入队列的操作仍会写入
+func (w *BulkWriter) Add(ctx context.Context, bwm *BulkWriteModel) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return ErrBulkWriterClosed
+	}
+	w.adding.Add(1)
+	w.mu.RUnlock()
+	defer w.adding.Done()
+
+	for _, m := range bwm.models {
+		select {
+		case w.queue <- m:
+		case <-w.closing:
+			return ErrBulkWriterClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Flush 立即写入已添加的操作，返回本次写入的错误
+func (w *BulkWriter) Flush(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	reply := make(chan error, 1)
+	select {
+	case w.flushCh <- reply:
+	case <-w.done:
+		return ErrBulkWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Errors 写入失败的错误，缓冲满时丢弃，Close 结束后关闭
+func (w *BulkWriter) Errors() <-chan error {
+	return w.errs
+}
+
+// Close 停止接收操作，写入队列中剩余的操作后返回最后一次写入的错误
+// ctx 结束时不再等待并返回 ctx 的错误，剩余的操作继续在后台写入
+func (w *BulkWriter) Close(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.closing)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return w.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *BulkWriter) run() {
+	defer close(w.done)
+	defer close(w.errs)
+
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]mongo.WriteModel, 0, w.opts.MaxOps)
+	// drain 取出队列中已有的操作，达到数量时写入
+	drain := func() {
+		for {
+			select {
+			case m := <-w.queue:
+				batch = append(batch, m)
+				if len(batch) >= w.opts.MaxOps {
+					batch, _ = w.write(batch)
+				}
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case m := <-w.queue:
+			batch = append(batch, m)
+			if len(batch) >= w.opts.MaxOps {
+				batch, _ = w.write(batch)
+			}
+		case <-ticker.C:
+			batch, _ = w.write(batch)
+		case reply := <-w.flushCh:
+			drain()
+			var err error
+			batch, err = w.write(batch)
+			reply <- err
+		case <-w.closing:
+			// 等待执行中的 Add 结束，之后不会再有新的操作加入队列
+			w.adding.Wait()
+			drain()
+			_, w.closeErr = w.write(batch)
+			return
+		}
+	}
+}
+
+// write 写入 batch，返回清空的 batch 与写入错误，错误同时上报 OnError 与 Errors
+func (w *BulkWriter) write(batch []mongo.WriteModel) ([]mongo.WriteModel, error) {
+	if len(batch) <= 0 {
+		return batch, nil
+	}
+
+	models := make([]mongo.WriteModel, len(batch))
+	copy(models, batch)
+	bwm := &BulkWriteModel{models: models}
+	bwm.SetOrdered(w.opts.Ordered)
+	if w.opts.WriteConcern != nil {
+		bwm.WriteConcern(*w.opts.WriteConcern)
+	}
+
+	ret, err := w.coll.BulkWrite(w.ctx, bwm)
+	if err != nil {
+		if w.opts.OnError != nil {
+			w.opts.OnError(err, ret)
+		}
+		select {
+		case w.errs <- err:
+		default:
+		}
+	}
+	return batch[:0], err
+}
+
+// sessionDetachedCtx 屏蔽 session 的 ctx，其他值与取消信号不变
+type sessionDetachedCtx struct {
+	context.Context
+}
+
+func (c sessionDetachedCtx) Value(key interface{}) interface{} {
+	v := c.Context.Value(key)
+	if _, ok := v.(mongo.Session); ok {
+		return nil
+	}
+	return v
+}
+
+// detachSession 去掉 ctx 中的 session，session 不能在多个 goroutine 中同时使用
+func detachSession(ctx context.Context) context.Context {
+	if ctx == nil || mongo.SessionFromContext(ctx) == nil {
+		return ctx
+	}
+	return sessionDetachedCtx{Context: ctx}
+}
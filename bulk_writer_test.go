@@ -0,0 +1,110 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestBulkWriter(t *testing.T) {
+	cli, err := NewClient(context.Background(), OptionsFromURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close(context.Background())
+
+	var failed int64
+	w := cli.Database("test").Collection("events").NewBulkWriter(&BulkWriterOptions{
+		MaxOps:        2,
+		FlushInterval: time.Hour,
+		OnError: func(err error, ret *BulkWriteResult) {
+			atomic.AddInt64(&failed, 1)
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := w.Add(context.Background(), NewBulkWriteModel().AddInsertOneModel(map[string]interface{}{"n": i})); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 2 个操作达到数量写入，剩余 1 个在 Close 时写入
+	if err = w.Close(context.Background()); err == nil {
+		t.Fatal("close must return the write error")
+	}
+	if n := atomic.LoadInt64(&failed); n != 2 {
+		t.Fatalf("expect 2 failed writes, got %d", n)
+	}
+	var errs int
+	for range w.Errors() {
+		errs++
+	}
+	if errs != 2 {
+		t.Fatalf("expect 2 errors, got %d", errs)
+	}
+
+	err = w.Add(context.Background(), NewBulkWriteModel().AddInsertOneModel(map[string]interface{}{"n": 3}))
+	if !errors.Is(err, ErrBulkWriterClosed) {
+		t.Fatalf("expect ErrBulkWriterClosed, got %v", err)
+	}
+	if err = w.Flush(context.Background()); !errors.Is(err, ErrBulkWriterClosed) {
+		t.Fatalf("expect ErrBulkWriterClosed, got %v", err)
+	}
+}
+
+func TestBulkWriterDetachSession(t *testing.T) {
+	cli, err := NewClient(context.Background(), OptionsFromURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close(context.Background())
+
+	sess, err := cli.mongoClient.StartSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.EndSession(context.Background())
+
+	ctx := mongo.NewSessionContext(WithTenant(context.Background(), "t1"), sess)
+	detached := detachSession(ctx)
+	if mongo.SessionFromContext(detached) != nil {
+		t.Fatal("session must be detached")
+	}
+	if tenant, ok := TenantFromContext(detached); !ok || tenant != "t1" {
+		t.Fatal("tenant must be kept", tenant)
+	}
+}
+
+func TestBulkWriterCloseWhileAdding(t *testing.T) {
+	// 没有后台写入，队列满时 Add 阻塞
+	w := &BulkWriter{
+		queue:   make(chan mongo.WriteModel),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	added := make(chan error, 1)
+	go func() {
+		added <- w.Add(context.Background(), NewBulkWriteModel().AddInsertOneModel(map[string]interface{}{"n": 1}))
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := w.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("close must honor ctx, got %v", err)
+	}
+	if err := <-added; !errors.Is(err, ErrBulkWriterClosed) {
+		t.Fatalf("blocked add must return ErrBulkWriterClosed, got %v", err)
+	}
+}
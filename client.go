@@ -3,14 +3,12 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"reflect"
-	"runtime"
+	"sync"
 	"time"
 
-	"github.com/assembly-hub/basics/util"
 	"go.mongodb.org/mongo-driver/bson/bsoncodec"
-	"go.mongodb.org/mongo-driver/bson/bsonoptions"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readconcern"
@@ -18,90 +16,82 @@ import (
 
 type SessionContext = mongo.SessionContext
 
+// ErrClientClosed client 已关闭
+var ErrClientClosed = errors.New("[client]: client is closed")
+
 type Client struct {
 	clientOptions []*options.ClientOptions
 	ctx           context.Context
 	mongoClient   *mongo.Client
+
+	// txCtxs 执行中的事务的父 ctx 及数量，用于事务绑定检查
+	txMu         sync.Mutex
+	txCtxs       map[context.Context]int
+	sessionCheck SessionCheck
+	tenant       *TenantConf
+
+	// 生命周期，记录执行中的操作，Close 时等待其结束
+	lifeMu   sync.Mutex
+	closed   bool
+	inFlight int64
+	idle     chan struct{}
+	// sessions 执行中的事务，Close 后绑定这些 session 的操作仍可执行
+	sessions map[mongo.Session]struct{}
+
+	monitor *clientMonitor
+	// collScanWarning ORM 查询全表扫描时输出警告
+	collScanWarning bool
+	logger          Logger
+	slowQuery       time.Duration
+	metrics         Metrics
+	tracer          Tracer
+	retryPolicy     *RetryPolicy
+	// registry 编码解码器，与 mongoClient 一致
+	registry *bsoncodec.Registry
 }
 
+// Connection 根据配置创建client，配置或连接错误会 panic
 func Connection(ctx context.Context, appName string, mongoConf *Conf) *Client {
-	if mongoConf.AuthDB == "" {
-		mongoConf.AuthDB = mongoConf.DB
-	}
-	if mongoConf.HostMaster == "" {
-		panic(fmt.Sprintf("mongotool[%s] HostMaster error", appName))
-	}
-	hosts := []string{mongoConf.HostMaster}
-
-	if mongoConf.HostSlave != "" {
-		hosts = append(hosts, mongoConf.HostSlave)
-	}
-
-	if mongoConf.DB == "" {
-		panic(fmt.Sprintf("mongotool[%s] db error", appName))
-	}
-
-	auth := ""
-	if mongoConf.User != "" {
-		auth = mongoConf.User
-		if mongoConf.Pass != "" {
-			auth += ":" + mongoConf.Pass
-		}
-
-		auth += "@"
-	}
-
-	var params []string
-	if mongoConf.ServerSelectionTimeoutMS <= 0 {
-		mongoConf.ServerSelectionTimeoutMS = 5000
-	}
-	params = append(params, fmt.Sprintf("serverSelectionTimeoutMS=%d", mongoConf.ServerSelectionTimeoutMS))
-
-	if mongoConf.ConnectTimeoutMS <= 0 {
-		mongoConf.ConnectTimeoutMS = 10000
+	client, err := NewClientByConf(ctx, appName, mongoConf)
+	if err != nil {
+		panic(err)
 	}
-	params = append(params, fmt.Sprintf("connectTimeoutMS=%d", mongoConf.ConnectTimeoutMS))
+	return client
+}
 
-	if mongoConf.AuthMechanism == "" {
-		mongoConf.AuthMechanism = "SCRAM-SHA-1"
+// NewClientByConf 根据配置创建client
+// mongoConf.Connect 为 true 时会 ping 检查连接
+func NewClientByConf(ctx context.Context, appName string, mongoConf *Conf) (*Client, error) {
+	if mongoConf == nil {
+		return nil, fmt.Errorf("mongotool[%s] conf is nil", appName)
 	}
-	params = append(params, fmt.Sprintf("authMechanism=%s", mongoConf.AuthMechanism))
-
-	params = append(params, fmt.Sprintf("authSource=%s", mongoConf.AuthDB))
 
-	if mongoConf.ReplicaSet != "" {
-		params = append(params, fmt.Sprintf("replicaSet=%s", mongoConf.ReplicaSet))
-	} else {
-		params = append(params, "connect=direct")
+	uri, err := mongoConf.URI()
+	if err != nil {
+		return nil, fmt.Errorf("mongotool[%s] %w", appName, err)
 	}
 
-	uri := fmt.Sprintf("mongodb://%s%s/%s?%s",
-		auth, util.JoinArr(hosts, ","), mongoConf.DB, util.JoinArr(params, "&"))
-
 	opts := OptionsFromURI(uri)
-
-	opts.AppName = &appName
-	minPoolSize := uint64(5)
-	maxPoolSize := uint64(runtime.GOMAXPROCS(0) * 5)
-
-	opts.MinPoolSize = &minPoolSize
-	opts.MaxPoolSize = &maxPoolSize
+	if appName != "" {
+		opts.SetAppName(appName)
+	}
 
 	client, err := NewClient(ctx, opts)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	if !mongoConf.Connect {
-		return client
+		return client, nil
 	}
 
 	err = client.Ping(ctx)
 	if err != nil {
-		panic(err)
+		_ = client.Close(ctx)
+		return nil, err
 	}
 
-	return client
+	return client, nil
 }
 
 func NewClient(ctx context.Context, opt *ClientOptions) (*Client, error) {
@@ -109,45 +99,141 @@ func NewClient(ctx context.Context, opt *ClientOptions) (*Client, error) {
 		return nil, fmt.Errorf("ctx or opt not be nil")
 	}
 
-	optList := []*options.ClientOptions{opt.ClientOptions, options.Client().SetRegistry(register())}
+	c := new(Client)
+	c.monitor = newClientMonitor(opt.ClientOptions)
+	c.registry = register(opt.codec)
+	optList := []*options.ClientOptions{opt.ClientOptions, c.monitor.options(), options.Client().SetRegistry(c.registry)}
 	client, err := mongo.Connect(ctx, optList...)
 	if err != nil {
 		return nil, err
 	}
-	c := new(Client)
 	c.clientOptions = optList
 	c.mongoClient = client
 	c.ctx = ctx
 	return c, nil
 }
 
-func register() *bsoncodec.Registry {
-	builder := bsoncodec.NewRegistryBuilder()
-
-	// 注册默认的编码和解码器
-	bsoncodec.DefaultValueEncoders{}.RegisterDefaultEncoders(builder)
-	bsoncodec.DefaultValueDecoders{}.RegisterDefaultDecoders(builder)
-
-	// 注册时间解码器
-	tTime := reflect.TypeOf(time.Time{})
-	tCodec := bsoncodec.NewTimeCodec(bsonoptions.TimeCodec().SetUseLocalTimeZone(true))
-	registry := builder.RegisterTypeDecoder(tTime, tCodec).Build()
-
-	return registry
-}
-
 func (c *Client) Ping(ctx context.Context) error {
 	ctxObj := c.ctx
 	if ctx != nil {
 		ctxObj = ctx
 	}
-	err := c.mongoClient.Ping(ctxObj, nil)
+	done, err := c.begin(ctxObj)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	err = c.mongoClient.Ping(ctxObj, nil)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// begin 记录一个执行中的操作，client 已关闭时返回 ErrClientClosed
+// ctx 绑定执行中的事务时，Close 后仍可执行，保证事务能够完成
+// 操作结束后必须调用返回的 done
+func (c *Client) begin(ctx context.Context) (done func(), err error) {
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+	if c.closed && !c.activeSession(ctx) {
+		return nil, ErrClientClosed
+	}
+
+	c.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(c.end)
+	}, nil
+}
+
+// activeSession ctx 是否绑定执行中的事务，需要持有 lifeMu
+func (c *Client) activeSession(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	sess := mongo.SessionFromContext(ctx)
+	if sess == nil {
+		return false
+	}
+	_, ok := c.sessions[sess]
+	return ok
+}
+
+// trackSession 登记执行中的事务，返回的函数在事务结束时调用
+func (c *Client) trackSession(sess mongo.Session) func() {
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+	if c.sessions == nil {
+		c.sessions = map[mongo.Session]struct{}{}
+	}
+	c.sessions[sess] = struct{}{}
+	return func() {
+		c.lifeMu.Lock()
+		defer c.lifeMu.Unlock()
+		delete(c.sessions, sess)
+	}
+}
+
+func (c *Client) end() {
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+	c.inFlight--
+	if c.inFlight <= 0 && c.idle != nil {
+		close(c.idle)
+		c.idle = nil
+	}
+}
+
+// Close 关闭client：拒绝新的操作，等待执行中的操作结束后断开连接
+// ctx 超时后强制断开连接并返回 ctx 的错误，重复调用返回 ErrClientClosed
+func (c *Client) Close(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	c.lifeMu.Lock()
+	if c.closed {
+		c.lifeMu.Unlock()
+		return ErrClientClosed
+	}
+	c.closed = true
+	idle := make(chan struct{})
+	if c.inFlight <= 0 {
+		close(idle)
+	} else {
+		c.idle = idle
+	}
+	c.lifeMu.Unlock()
+
+	var waitErr error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+
+	disconnectCtx := ctx
+	if waitErr != nil {
+		disconnectCtx = context.Background()
+	}
+	if err := c.mongoClient.Disconnect(disconnectCtx); err != nil {
+		if waitErr != nil {
+			return fmt.Errorf("%w, disconnect: %v", waitErr, err)
+		}
+		return err
+	}
+	return waitErr
+}
+
+// InFlight 执行中的操作数量
+func (c *Client) InFlight() int64 {
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+	return c.inFlight
+}
+
 func (c *Client) Ctx() context.Context {
 	return c.ctx
 }
@@ -156,6 +242,22 @@ func (c *Client) Ctx() context.Context {
 // 要求mongo 版本 4.0起
 // 需要mongo副本集群
 func (c *Client) NewSession(fn func(sessionCtx SessionContext) error) error {
+	return c.NewSessionContext(c.ctx, fn)
+}
+
+// NewSessionContext 以 ctx 为父 ctx 执行事务，同 NewSession
+// 事务绑定检查只针对使用 ctx 创建、未绑定事务的ORM，如：使用请求的 ctx 开启事务
+func (c *Client) NewSessionContext(ctx context.Context, fn func(sessionCtx SessionContext) error) error {
+	if ctx == nil {
+		ctx = c.ctx
+	}
+	// 事务占用一个执行中的操作，Close 等待事务结束
+	done, err := c.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	// session
 	sessionOpts := options.Session().SetDefaultReadConcern(readconcern.Majority())
 	session, err := c.mongoClient.StartSession(sessionOpts)
@@ -163,9 +265,13 @@ func (c *Client) NewSession(fn func(sessionCtx SessionContext) error) error {
 		return err
 	}
 	defer session.EndSession(context.Background())
+	defer c.trackSession(session)()
+
+	c.enterTx(ctx)
+	defer c.leaveTx(ctx)
 
 	// transaction
-	err = mongo.WithSession(c.ctx, session, func(sessionCtx SessionContext) (err error) {
+	err = mongo.WithSession(ctx, session, func(sessionCtx SessionContext) (err error) {
 		defer func() {
 			if p := recover(); p != nil {
 				errTx := session.AbortTransaction(context.Background())
@@ -197,12 +303,19 @@ func (c *Client) NewSession(fn func(sessionCtx SessionContext) error) error {
 func (c *Client) Database(dbName string) *Database {
 	db := new(Database)
 	db.Client = c
+	db.ctx = c.ctx
 	db.dbName = dbName
 	db.db = c.mongoClient.Database(dbName)
 	return db
 }
 
 func (c *Client) TryDatabase(dbName string) (db *Database, exist bool, err error) {
+	done, err := c.begin(c.ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer done()
+
 	names, err := c.mongoClient.ListDatabaseNames(c.ctx, map[string]string{"name": dbName})
 	if err != nil {
 		return nil, false, err
@@ -215,6 +328,7 @@ func (c *Client) TryDatabase(dbName string) (db *Database, exist bool, err error
 
 	db = new(Database)
 	db.Client = c
+	db.ctx = c.ctx
 	db.dbName = dbName
 	db.db = c.mongoClient.Database(dbName)
 	return db, exist, nil
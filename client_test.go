@@ -0,0 +1,108 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestClientClose(t *testing.T) {
+	cli, err := NewClient(context.Background(), OptionsFromURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := cli.begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	closed := make(chan error, 1)
+	go func() {
+		closed <- cli.Close(ctx)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if _, err = cli.begin(context.Background()); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("expect ErrClientClosed, got %v", err)
+	}
+
+	done()
+	if err = <-closed; err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if cli.InFlight() != 0 {
+		t.Fatalf("in flight must be 0, got %d", cli.InFlight())
+	}
+	if err = cli.Close(context.Background()); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("expect ErrClientClosed, got %v", err)
+	}
+}
+
+func TestClientCloseTimeout(t *testing.T) {
+	cli, err := NewClient(context.Background(), OptionsFromURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := cli.begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err = cli.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+}
+
+func TestClientCloseActiveSession(t *testing.T) {
+	cli, err := NewClient(context.Background(), OptionsFromURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同 NewSessionContext：事务占用一个执行中的操作并登记 session
+	done, err := cli.begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := cli.mongoClient.StartSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.EndSession(context.Background())
+	untrack := cli.trackSession(session)
+	sessionCtx := mongo.NewSessionContext(context.Background(), session)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- cli.Close(context.Background())
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err = cli.begin(context.Background()); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("expect ErrClientClosed, got %v", err)
+	}
+	opDone, err := cli.begin(sessionCtx)
+	if err != nil {
+		t.Fatalf("operation in transaction must pass, got %v", err)
+	}
+	opDone()
+
+	untrack()
+	done()
+	if err = <-closed; err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if _, err = cli.begin(sessionCtx); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("expect ErrClientClosed after transaction, got %v", err)
+	}
+}
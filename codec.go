@@ -0,0 +1,174 @@
+// Package mongo
+package mongo
+
+import (
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonoptions"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+var (
+	tTime = reflect.TypeOf(time.Time{})
+	tMap  = reflect.TypeOf(map[string]interface{}{})
+)
+
+// codecConf 编码解码配置，nil 为默认配置
+type codecConf struct {
+	codecs   []typeCodec
+	typeMap  map[bsontype.Type]reflect.Type
+	location *time.Location
+	truncate time.Duration
+	// nullAsZero null 解码到指针时为指向零值的指针
+	nullAsZero     bool
+	interfaceAsMap bool
+}
+
+type typeCodec struct {
+	t   reflect.Type
+	enc bsoncodec.ValueEncoder
+	dec bsoncodec.ValueDecoder
+}
+
+func (o *ClientOptions) codecConf() *codecConf {
+	if o.codec == nil {
+		o.codec = &codecConf{typeMap: map[bsontype.Type]reflect.Type{}}
+	}
+	return o.codec
+}
+
+// RegisterCodec 注册类型的编码和解码器，enc、dec 可以为 nil
+// t 为接口类型时，对实现该接口的所有类型生效，如：枚举、金额等领域类型
+func (o *ClientOptions) RegisterCodec(t reflect.Type, enc bsoncodec.ValueEncoder, dec bsoncodec.ValueDecoder) *ClientOptions {
+	if t == nil {
+		panic("codec type can not nil")
+	}
+	conf := o.codecConf()
+	conf.codecs = append(conf.codecs, typeCodec{t: t, enc: enc, dec: dec})
+	return o
+}
+
+// RegisterTypeMapEntry 设置 bson 类型解码到 interface{} 时的 Go 类型，如：bsontype.EmbeddedDocument -> bson.M
+func (o *ClientOptions) RegisterTypeMapEntry(bt bsontype.Type, t reflect.Type) *ClientOptions {
+	o.codecConf().typeMap[bt] = t
+	return o
+}
+
+// SetTimeZone 设置 time.Time 解码的时区，默认本地时区
+func (o *ClientOptions) SetTimeZone(loc *time.Location) *ClientOptions {
+	o.codecConf().location = loc
+	return o
+}
+
+// SetTimeTruncate 写入 time.Time 前按 d 截断，如：time.Second，小于等于 0 不截断（bson 时间精度为毫秒）
+func (o *ClientOptions) SetTimeTruncate(d time.Duration) *ClientOptions {
+	o.codecConf().truncate = d
+	return o
+}
+
+// SetDecodeNullAsZero null、undefined 解码到指针时为指向零值的指针，默认为 nil
+// 其他类型 driver 默认解码为零值
+func (o *ClientOptions) SetDecodeNullAsZero(b bool) *ClientOptions {
+	o.codecConf().nullAsZero = b
+	return o
+}
+
+// SetDecodeInterfaceAsMap 文档解码到 interface{} 时为 map[string]interface{}，默认为 primitive.D
+func (o *ClientOptions) SetDecodeInterfaceAsMap(b bool) *ClientOptions {
+	o.codecConf().interfaceAsMap = b
+	return o
+}
+
+func register(conf *codecConf) *bsoncodec.Registry {
+	builder := bsoncodec.NewRegistryBuilder()
+
+	// 注册默认的编码和解码器
+	bsoncodec.DefaultValueEncoders{}.RegisterDefaultEncoders(builder)
+	bsoncodec.DefaultValueDecoders{}.RegisterDefaultDecoders(builder)
+
+	// 注册 UUID、Decimal 编码和解码器
+	builder.RegisterTypeEncoder(tUUID, bsoncodec.ValueEncoderFunc(uuidEncodeValue))
+	builder.RegisterTypeDecoder(tUUID, bsoncodec.ValueDecoderFunc(uuidDecodeValue))
+	builder.RegisterTypeEncoder(tDecimal, bsoncodec.ValueEncoderFunc(decimalEncodeValue))
+	builder.RegisterTypeDecoder(tDecimal, bsoncodec.ValueDecoderFunc(decimalDecodeValue))
+
+	if conf == nil {
+		conf = &codecConf{}
+	}
+
+	// 注册时间编码和解码器，默认解码为本地时区
+	tCodec := bsoncodec.NewTimeCodec(bsonoptions.TimeCodec().SetUseLocalTimeZone(conf.location == nil))
+	if conf.location != nil {
+		loc := conf.location
+		builder.RegisterTypeDecoder(tTime, bsoncodec.ValueDecoderFunc(
+			func(dc bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+				if err := tCodec.DecodeValue(dc, vr, val); err != nil {
+					return err
+				}
+				val.Set(reflect.ValueOf(val.Interface().(time.Time).In(loc)))
+				return nil
+			}))
+	} else {
+		builder.RegisterTypeDecoder(tTime, tCodec)
+	}
+	if conf.truncate > 0 {
+		d := conf.truncate
+		builder.RegisterTypeEncoder(tTime, bsoncodec.ValueEncoderFunc(
+			func(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+				if !val.IsValid() || val.Type() != tTime {
+					return bsoncodec.ValueEncoderError{Name: "TimeEncodeValue", Types: []reflect.Type{tTime}, Received: val}
+				}
+				return tCodec.EncodeValue(ec, vw, reflect.ValueOf(val.Interface().(time.Time).Truncate(d)))
+			}))
+	}
+
+	if conf.nullAsZero {
+		ptrCodec := bsoncodec.NewPointerCodec()
+		builder.RegisterDefaultDecoder(reflect.Ptr, bsoncodec.ValueDecoderFunc(
+			func(dc bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+				switch vr.Type() {
+				case bsontype.Null:
+					if !val.CanSet() {
+						break
+					}
+					val.Set(reflect.New(val.Type().Elem()))
+					return vr.ReadNull()
+				case bsontype.Undefined:
+					if !val.CanSet() {
+						break
+					}
+					val.Set(reflect.New(val.Type().Elem()))
+					return vr.ReadUndefined()
+				}
+				return ptrCodec.DecodeValue(dc, vr, val)
+			}))
+	}
+	if conf.interfaceAsMap {
+		builder.RegisterTypeMapEntry(bsontype.EmbeddedDocument, tMap)
+	}
+	for bt, t := range conf.typeMap {
+		builder.RegisterTypeMapEntry(bt, t)
+	}
+
+	for _, c := range conf.codecs {
+		if c.t.Kind() == reflect.Interface {
+			if c.enc != nil {
+				builder.RegisterHookEncoder(c.t, c.enc)
+			}
+			if c.dec != nil {
+				builder.RegisterHookDecoder(c.t, c.dec)
+			}
+			continue
+		}
+		if c.enc != nil {
+			builder.RegisterTypeEncoder(c.t, c.enc)
+		}
+		if c.dec != nil {
+			builder.RegisterTypeDecoder(c.t, c.dec)
+		}
+	}
+	return builder.Build()
+}
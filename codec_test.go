@@ -0,0 +1,98 @@
+package mongo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+type testStatus int
+
+func (s testStatus) String() string {
+	return []string{"inactive", "active"}[s]
+}
+
+type codecDoc struct {
+	Status  testStatus  `bson:"status"`
+	At      time.Time   `bson:"at"`
+	Count   *int        `bson:"count"`
+	Payload interface{} `bson:"payload"`
+}
+
+func TestRegisterCodec(t *testing.T) {
+	tStatus := reflect.TypeOf(testStatus(0))
+	enc := bsoncodec.ValueEncoderFunc(func(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+		return vw.WriteString(val.Interface().(testStatus).String())
+	})
+	dec := bsoncodec.ValueDecoderFunc(func(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+		s, err := vr.ReadString()
+		if err != nil {
+			return err
+		}
+		if s == "active" {
+			val.SetInt(1)
+		} else {
+			val.SetInt(0)
+		}
+		return nil
+	})
+
+	loc := time.FixedZone("UTC+8", 8*3600)
+	opts := CreateEmptyOptions().
+		RegisterCodec(tStatus, enc, dec).
+		SetTimeZone(loc).
+		SetTimeTruncate(time.Second).
+		SetDecodeNullAsZero(true).
+		SetDecodeInterfaceAsMap(true)
+	registry := register(opts.codec)
+
+	at := time.Date(2022, 5, 1, 10, 0, 0, 500*int(time.Millisecond), time.UTC)
+	raw, err := bson.MarshalWithRegistry(registry, codecDoc{Status: 1, At: at, Payload: map[string]interface{}{"a": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := bson.Raw(raw).Lookup("status").StringValue(); v != "active" {
+		t.Fatalf("custom encoder not used: %s", v)
+	}
+
+	var doc codecDoc
+	if err = bson.UnmarshalWithRegistry(registry, raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Status != 1 {
+		t.Fatalf("custom decoder not used: %v", doc.Status)
+	}
+	if doc.At.Location() != loc || !doc.At.Equal(at.Truncate(time.Second)) {
+		t.Fatalf("time zone or truncate error: %v", doc.At)
+	}
+	if doc.Count == nil || *doc.Count != 0 {
+		t.Fatalf("null must be decoded as zero pointer: %v", doc.Count)
+	}
+	if _, ok := doc.Payload.(map[string]interface{}); !ok {
+		t.Fatalf("interface must be decoded as map: %T", doc.Payload)
+	}
+
+	registry = register(CreateEmptyOptions().RegisterTypeMapEntry(bsontype.String, reflect.TypeOf([]byte{})).codec)
+	var m map[string]interface{}
+	if err = bson.UnmarshalWithRegistry(registry, raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["status"].([]byte); !ok {
+		t.Fatalf("type map entry not used: %T", m["status"])
+	}
+
+	doc = codecDoc{}
+	raw, _ = bson.Marshal(bson.M{"at": at, "count": nil, "payload": bson.M{"a": 1}})
+	if err = bson.UnmarshalWithRegistry(register(nil), raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Count != nil || doc.At.Location() != time.Local || !strings.Contains(reflect.TypeOf(doc.Payload).String(), "primitive.D") {
+		t.Fatalf("default registry changed: %+v", doc)
+	}
+}
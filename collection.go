@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -16,16 +15,32 @@ import (
 type Collection struct {
 	*Database
 
+	ctx            context.Context
 	collectionName string
 	collection     *mongo.Collection
+	// optsErr WithOptions 的无效选项或多租户路由错误，操作时返回
+	optsErr error
+}
+
+// WithSession 返回绑定事务上下文的集合副本，ctx 参数为 nil 的操作均在该事务中执行
+func (c *Collection) WithSession(sessionCtx SessionContext) *Collection {
+	newC := *c
+	newC.ctx = sessionCtx
+	return &newC
 }
 
 // CreateOneIndex 创建索引
-func (c *Collection) CreateOneIndex(ctx context.Context, indexName string, keys []Index, indexUnique bool) error {
+func (c *Collection) CreateOneIndex(ctx context.Context, indexName string, keys []Index, indexUnique bool) (err error) {
+	ctxObj, end, err := c.start(ctx, "CreateOneIndex")
+	if err != nil {
+		return err
+	}
+	defer end(&err)
+
 	indexView := c.collection.Indexes()
 
 	if len(keys) <= 0 {
-		return errors.New("index keys have nothing")
+		return fmt.Errorf("%w: index keys have nothing", ErrInvalidQuery)
 	}
 
 	indexKeys := bson.D{}
@@ -41,11 +56,10 @@ func (c *Collection) CreateOneIndex(ctx context.Context, indexName string, keys
 		},
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
-	}
-	_, err := indexView.CreateOne(ctxObj, indexModel)
+	err = c.retry(ctxObj, "CreateOneIndex", true, func() error {
+		_, err := indexView.CreateOne(ctxObj, indexModel)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -53,11 +67,17 @@ func (c *Collection) CreateOneIndex(ctx context.Context, indexName string, keys
 }
 
 // CreateManyIndex 创建索引
-func (c *Collection) CreateManyIndex(ctx context.Context, indexList []ManyIndex) error {
+func (c *Collection) CreateManyIndex(ctx context.Context, indexList []ManyIndex) (err error) {
+	ctxObj, end, err := c.start(ctx, "CreateManyIndex")
+	if err != nil {
+		return err
+	}
+	defer end(&err)
+
 	indexView := c.collection.Indexes()
 
 	if len(indexList) <= 0 {
-		return errors.New("index keys have nothing")
+		return fmt.Errorf("%w: index keys have nothing", ErrInvalidQuery)
 	}
 
 	var indexModelList []mongo.IndexModel
@@ -76,11 +96,10 @@ func (c *Collection) CreateManyIndex(ctx context.Context, indexList []ManyIndex)
 		})
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
-	}
-	_, err := indexView.CreateMany(ctxObj, indexModelList)
+	err = c.retry(ctxObj, "CreateManyIndex", true, func() error {
+		_, err := indexView.CreateMany(ctxObj, indexModelList)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -88,34 +107,58 @@ func (c *Collection) CreateManyIndex(ctx context.Context, indexList []ManyIndex)
 }
 
 // InsertDoc
-// 添加一个文档
-func (c *Collection) InsertDoc(ctx context.Context, doc map[string]interface{}) (string, error) {
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+// 添加一个文档，返回主键，ObjectID 转换为 hex 字符串，其他类型原样返回
+func (c *Collection) InsertDoc(ctx context.Context, doc map[string]interface{}, opts ...*InsertOptions) (ret interface{}, err error) {
+	ctxObj, end, err := c.start(ctx, "InsertDoc")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	doc, err = c.stampTenant(ctxObj, doc)
+	if err != nil {
+		return nil, err
+	}
+	coll := c.collection
+	if op := lastOption(opts); op != nil {
+		if coll, err = c.withConcern(nil, &op.BasicWriteOptions); err != nil {
+			return nil, err
+		}
 	}
-	insertOneResult, err := c.collection.InsertOne(ctxObj, doc)
+	insertOneResult, err := coll.InsertOne(ctxObj, doc)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return insertOneResult.InsertedID.(primitive.ObjectID).Hex(), nil
+	return insertedID(insertOneResult.InsertedID), nil
 }
 
 // InsertDocs
-// 添加数据
-func (c *Collection) InsertDocs(ctx context.Context, docs []interface{}, ordered bool) ([]string, error) {
+// 添加数据，返回的主键同 InsertDoc
+func (c *Collection) InsertDocs(ctx context.Context, docs []interface{}, ordered bool, opts ...*InsertOptions) (ret []interface{}, err error) {
+	ctxObj, end, err := c.start(ctx, "InsertDocs")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
 	insertManyOpts := options.InsertMany().SetOrdered(ordered)
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+	docs, err = c.stampTenantDocs(ctxObj, docs)
+	if err != nil {
+		return nil, err
+	}
+	coll := c.collection
+	if op := lastOption(opts); op != nil {
+		if coll, err = c.withConcern(nil, &op.BasicWriteOptions); err != nil {
+			return nil, err
+		}
 	}
-	insertManyResult, err := c.collection.InsertMany(ctxObj, docs, insertManyOpts)
+	insertManyResult, err := coll.InsertMany(ctxObj, docs, insertManyOpts)
 	if err != nil {
 		return nil, err
 	}
-	rets := make([]string, len(insertManyResult.InsertedIDs))
+	rets := make([]interface{}, len(insertManyResult.InsertedIDs))
 	for i, v := range insertManyResult.InsertedIDs {
-		rets[i] = v.(primitive.ObjectID).Hex()
+		rets[i] = insertedID(v)
 	}
 	return rets, nil
 }
@@ -125,13 +168,25 @@ func (c *Collection) InsertDocs(ctx context.Context, docs []interface{}, ordered
 // results slice 结果返回，可以是map or struct
 // opts 查询参数选择
 func (c *Collection) FindDocs(ctx context.Context,
-	filter *Query, results interface{}, opts *FindOptions) error {
+	filter *Query, results interface{}, opts *FindOptions) (err error) {
+	ctxObj, end, err := c.start(ctx, "FindDocs")
+	if err != nil {
+		return err
+	}
+	defer end(&err)
+
 	if filter == nil {
 		filter = NewQuery()
 	}
 
+	coll := c.collection
 	mongoOpts := options.Find()
 	if opts != nil {
+		if coll, err = c.withConcern(&opts.BasicReadOptions, nil); err != nil {
+			return err
+		}
+		opts.applyFind(mongoOpts)
+
 		if opts.field != nil {
 			mongoOpts.SetProjection(opts.field)
 		}
@@ -153,33 +208,42 @@ func (c *Collection) FindDocs(ctx context.Context,
 		}
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
-	}
-	cur, err := c.collection.Find(ctxObj, filter.Cond(), mongoOpts)
+	cond, err := c.cond(ctxObj, filter)
 	if err != nil {
 		return err
 	}
-
-	if err = cur.All(ctxObj, results); err != nil {
-		return err
-	}
-
-	return nil
+	return c.retry(ctxObj, "FindDocs", true, func() error {
+		cur, err := coll.Find(ctxObj, cond, mongoOpts)
+		if err != nil {
+			return err
+		}
+		return cur.All(ctxObj, results)
+	})
 }
 
 // FindOne
 // filter 查询对象
 // results 结果返回，可以是map or struct
 func (c *Collection) FindOne(ctx context.Context,
-	filter *Query, result interface{}, opts *FindOneOptions) error {
+	filter *Query, result interface{}, opts *FindOneOptions) (err error) {
+	ctxObj, end, err := c.start(ctx, "FindOne")
+	if err != nil {
+		return err
+	}
+	defer end(&err)
+
 	if filter == nil {
 		filter = NewQuery()
 	}
 
+	coll := c.collection
 	mongoOpts := options.FindOne()
 	if opts != nil {
+		if coll, err = c.withConcern(&opts.BasicReadOptions, nil); err != nil {
+			return err
+		}
+		opts.applyFindOne(mongoOpts)
+
 		if opts.field != nil {
 			mongoOpts.SetProjection(opts.field)
 		}
@@ -197,21 +261,31 @@ func (c *Collection) FindOne(ctx context.Context,
 		}
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
-	}
-	singleResult := c.collection.FindOne(ctxObj, filter.Cond(), mongoOpts)
-	if err := singleResult.Decode(result); err != nil && err != mongo.ErrNoDocuments {
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
 		return err
 	}
-	return nil
+	return c.retry(ctxObj, "FindOne", true, func() error {
+		singleResult := coll.FindOne(ctxObj, cond, mongoOpts)
+		return decodeSingle(singleResult, result, opts != nil && opts.errorOnMissing)
+	})
 }
 
 func (c *Collection) FindOneAndDelete(ctx context.Context,
-	filter *Query, delDoc interface{}, opts *FindOneAndDelete) error {
+	filter *Query, delDoc interface{}, opts *FindOneAndDelete) (err error) {
+	ctxObj, end, err := c.start(ctx, "FindOneAndDelete")
+	if err != nil {
+		return err
+	}
+	defer end(&err)
+
+	coll := c.collection
 	mongoOpts := options.FindOneAndDelete()
 	if opts != nil {
+		if coll, err = c.withConcern(nil, &opts.BasicWriteOptions); err != nil {
+			return err
+		}
+
 		if opts.field != nil {
 			mongoOpts.SetProjection(opts.field)
 		}
@@ -221,31 +295,33 @@ func (c *Collection) FindOneAndDelete(ctx context.Context,
 		}
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
-	}
-	singleResult := c.collection.FindOneAndDelete(ctxObj, filter.Cond(), mongoOpts)
-	if delDoc == nil {
-		return nil
-	}
-
-	if err := singleResult.Decode(delDoc); err != nil {
-		if err != mongo.ErrNoDocuments {
-			return err
-		}
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return err
 	}
-	return nil
+	singleResult := coll.FindOneAndDelete(ctxObj, cond, mongoOpts)
+	return decodeSingle(singleResult, delDoc, opts != nil && opts.errorOnMissing)
 }
 
 func (c *Collection) FindOneAndReplace(ctx context.Context,
-	filter *Query, newDoc map[string]interface{}, oldDoc interface{}, opts *FindOneAndReplace) error {
+	filter *Query, newDoc map[string]interface{}, oldDoc interface{}, opts *FindOneAndReplace) (err error) {
+	ctxObj, end, err := c.start(ctx, "FindOneAndReplace")
+	if err != nil {
+		return err
+	}
+	defer end(&err)
+
 	if newDoc == nil {
-		return fmt.Errorf("newDoc is not nil")
+		return fmt.Errorf("%w: newDoc is not nil", ErrInvalidQuery)
 	}
 
+	coll := c.collection
 	mongoOpts := options.FindOneAndReplace()
 	if opts != nil {
+		if coll, err = c.withConcern(nil, &opts.BasicWriteOptions); err != nil {
+			return err
+		}
+
 		if opts.field != nil {
 			mongoOpts.SetProjection(opts.field)
 		}
@@ -259,31 +335,37 @@ func (c *Collection) FindOneAndReplace(ctx context.Context,
 		}
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
-	}
-	singleResult := c.collection.FindOneAndReplace(ctxObj, filter.Cond(), newDoc, mongoOpts)
-	if oldDoc == nil {
-		return nil
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return err
 	}
-
-	if err := singleResult.Decode(oldDoc); err != nil {
-		if err != mongo.ErrNoDocuments {
-			return err
-		}
+	newDoc, err = c.stampTenant(ctxObj, newDoc)
+	if err != nil {
+		return err
 	}
-	return nil
+	singleResult := coll.FindOneAndReplace(ctxObj, cond, newDoc, mongoOpts)
+	return decodeSingle(singleResult, oldDoc, opts != nil && opts.errorOnMissing)
 }
 
 func (c *Collection) FindOneAndUpdate(ctx context.Context,
-	filter *Query, upDoc map[string]interface{}, oldDoc interface{}, opts *FindOneAndUpdate) error {
+	filter *Query, upDoc map[string]interface{}, oldDoc interface{}, opts *FindOneAndUpdate) (err error) {
+	ctxObj, end, err := c.start(ctx, "FindOneAndUpdate")
+	if err != nil {
+		return err
+	}
+	defer end(&err)
+
 	if upDoc == nil {
-		return fmt.Errorf("upDoc is nil")
+		return fmt.Errorf("%w: upDoc is nil", ErrInvalidQuery)
 	}
 
+	coll := c.collection
 	mongoOpts := options.FindOneAndUpdate()
 	if opts != nil {
+		if coll, err = c.withConcern(nil, &opts.BasicWriteOptions); err != nil {
+			return err
+		}
+
 		if opts.field != nil {
 			mongoOpts.SetProjection(opts.field)
 		}
@@ -300,38 +382,40 @@ func (c *Collection) FindOneAndUpdate(ctx context.Context,
 	delete(upDoc, "_id")
 
 	if len(upDoc) <= 0 {
-		return fmt.Errorf("upDoc is empty")
+		return fmt.Errorf("%w: upDoc is empty", ErrInvalidQuery)
 	}
 
 	upObj := map[string]interface{}{
 		"$set": upDoc,
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
-	}
-	singleResult := c.collection.FindOneAndUpdate(ctxObj, filter.Cond(), upObj, mongoOpts)
-	if oldDoc == nil {
-		return nil
-	}
-
-	if err := singleResult.Decode(oldDoc); err != nil {
-		if err != mongo.ErrNoDocuments {
-			return err
-		}
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return err
 	}
-	return nil
+	singleResult := coll.FindOneAndUpdate(ctxObj, cond, upObj, mongoOpts)
+	return decodeSingle(singleResult, oldDoc, opts != nil && opts.errorOnMissing)
 }
 
 func (c *Collection) FindOneAndUpdateCustom(ctx context.Context,
-	filter *Query, customDoc map[string]interface{}, oldDoc interface{}, opts *FindOneAndUpdate) error {
+	filter *Query, customDoc map[string]interface{}, oldDoc interface{}, opts *FindOneAndUpdate) (err error) {
+	ctxObj, end, err := c.start(ctx, "FindOneAndUpdateCustom")
+	if err != nil {
+		return err
+	}
+	defer end(&err)
+
 	if customDoc == nil {
-		return fmt.Errorf("customDoc is nil")
+		return fmt.Errorf("%w: customDoc is nil", ErrInvalidQuery)
 	}
 
+	coll := c.collection
 	mongoOpts := options.FindOneAndUpdate()
 	if opts != nil {
+		if coll, err = c.withConcern(nil, &opts.BasicWriteOptions); err != nil {
+			return err
+		}
+
 		if opts.field != nil {
 			mongoOpts.SetProjection(opts.field)
 		}
@@ -345,31 +429,33 @@ func (c *Collection) FindOneAndUpdateCustom(ctx context.Context,
 		}
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
-	}
-	singleResult := c.collection.FindOneAndUpdate(ctxObj, filter.Cond(), customDoc, mongoOpts)
-	if oldDoc == nil {
-		return nil
-	}
-
-	if err := singleResult.Decode(oldDoc); err != nil {
-		if err != mongo.ErrNoDocuments {
-			return err
-		}
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return err
 	}
-	return nil
+	singleResult := coll.FindOneAndUpdate(ctxObj, cond, customDoc, mongoOpts)
+	return decodeSingle(singleResult, oldDoc, opts != nil && opts.errorOnMissing)
 }
 
 func (c *Collection) UpdateOne(ctx context.Context,
-	filter *Query, upDoc map[string]interface{}, opts *Update) (*UpdateResult, error) {
+	filter *Query, upDoc map[string]interface{}, opts *Update) (ret *UpdateResult, err error) {
+	ctxObj, end, err := c.start(ctx, "UpdateOne")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
 	if upDoc == nil {
-		return nil, fmt.Errorf("upDoc is not nil")
+		return nil, fmt.Errorf("%w: upDoc is not nil", ErrInvalidQuery)
 	}
 
+	coll := c.collection
 	updateOneOpts := options.Update()
 	if opts != nil {
+		if coll, err = c.withConcern(nil, &opts.BasicWriteOptions); err != nil {
+			return nil, err
+		}
+
 		if opts.upsert != nil {
 			updateOneOpts.SetUpsert(*opts.upsert)
 		}
@@ -378,16 +464,20 @@ func (c *Collection) UpdateOne(ctx context.Context,
 	delete(upDoc, "_id")
 
 	if len(upDoc) <= 0 {
-		return nil, fmt.Errorf("upDoc is empty")
+		return nil, fmt.Errorf("%w: upDoc is empty", ErrInvalidQuery)
 	}
 
 	updateOneSet := bson.M{"$set": upDoc}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
 	}
-	updateResult, err := c.collection.UpdateOne(ctxObj, filter.Cond(), updateOneSet, updateOneOpts)
+	var updateResult *mongo.UpdateResult
+	err = c.retry(ctxObj, "UpdateOne", true, func() (err error) {
+		updateResult, err = coll.UpdateOne(ctxObj, cond, updateOneSet, updateOneOpts)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -401,13 +491,24 @@ func (c *Collection) UpdateOne(ctx context.Context,
 }
 
 func (c *Collection) UpdateMany(ctx context.Context,
-	filter *Query, upDoc map[string]interface{}, opts *Update) (*UpdateResult, error) {
+	filter *Query, upDoc map[string]interface{}, opts *Update) (ret *UpdateResult, err error) {
+	ctxObj, end, err := c.start(ctx, "UpdateMany")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
 	if upDoc == nil {
-		return nil, fmt.Errorf("upDoc is not nil")
+		return nil, fmt.Errorf("%w: upDoc is not nil", ErrInvalidQuery)
 	}
 
+	coll := c.collection
 	updateManyOpts := options.Update()
 	if opts != nil {
+		if coll, err = c.withConcern(nil, &opts.BasicWriteOptions); err != nil {
+			return nil, err
+		}
+
 		if opts.upsert != nil {
 			updateManyOpts.SetUpsert(*opts.upsert)
 		}
@@ -416,16 +517,20 @@ func (c *Collection) UpdateMany(ctx context.Context,
 	delete(upDoc, "_id")
 
 	if len(upDoc) <= 0 {
-		return nil, fmt.Errorf("upDoc is empty")
+		return nil, fmt.Errorf("%w: upDoc is empty", ErrInvalidQuery)
 	}
 
 	updateManeySet := bson.M{"$set": upDoc}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
 	}
-	updateResult, err := c.collection.UpdateMany(ctxObj, filter.Cond(), updateManeySet, updateManyOpts)
+	var updateResult *mongo.UpdateResult
+	err = c.retry(ctxObj, "UpdateMany", true, func() (err error) {
+		updateResult, err = coll.UpdateMany(ctxObj, cond, updateManeySet, updateManyOpts)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -439,25 +544,87 @@ func (c *Collection) UpdateMany(ctx context.Context,
 }
 
 func (c *Collection) UpdateOneCustom(ctx context.Context,
-	filter *Query, update updateType, updateDoc map[string]interface{}, opts *Update) (*UpdateResult, error) {
+	filter *Query, update updateType, updateDoc map[string]interface{}, opts *Update) (ret *UpdateResult, err error) {
+	ctxObj, end, err := c.start(ctx, "UpdateOneCustom")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
 	if updateDoc == nil {
-		return nil, fmt.Errorf("updateDoc is not nil")
+		return nil, fmt.Errorf("%w: updateDoc is not nil", ErrInvalidQuery)
 	}
 
 	updateSet := bson.M{update.String(): updateDoc}
 
+	coll := c.collection
 	updateOneOpts := options.Update()
 	if opts != nil {
+		if coll, err = c.withConcern(nil, &opts.BasicWriteOptions); err != nil {
+			return nil, err
+		}
+
 		if opts.upsert != nil {
 			updateOneOpts.SetUpsert(*opts.upsert)
 		}
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
 	}
-	updateResult, err := c.collection.UpdateOne(ctxObj, filter.Cond(), updateSet, updateOneOpts)
+	var updateResult *mongo.UpdateResult
+	err = c.retry(ctxObj, "UpdateOneCustom", idempotentUpdate(updateSet), func() (err error) {
+		updateResult, err = coll.UpdateOne(ctxObj, cond, updateSet, updateOneOpts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateResult{
+		MatchedCount:  updateResult.MatchedCount,
+		ModifiedCount: updateResult.ModifiedCount,
+		UpsertedCount: updateResult.UpsertedCount,
+		UpsertedID:    updateResult.UpsertedID,
+	}, nil
+}
+
+// UpdateOneOps 同时使用多个更新操作更新一个文档，空的操作忽略
+func (c *Collection) UpdateOneOps(ctx context.Context,
+	filter *Query, ops UpdateOps, opts *Update) (ret *UpdateResult, err error) {
+	ctxObj, end, err := c.start(ctx, "UpdateOneOps")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	updateSet := ops.document()
+	if len(updateSet) <= 0 {
+		return nil, fmt.Errorf("%w: update ops is empty", ErrInvalidQuery)
+	}
+
+	coll := c.collection
+	updateOneOpts := options.Update()
+	if opts != nil {
+		if coll, err = c.withConcern(nil, &opts.BasicWriteOptions); err != nil {
+			return nil, err
+		}
+
+		if opts.upsert != nil {
+			updateOneOpts.SetUpsert(*opts.upsert)
+		}
+	}
+
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
+	}
+	var updateResult *mongo.UpdateResult
+	err = c.retry(ctxObj, "UpdateOneOps", idempotentUpdate(updateSet), func() (err error) {
+		updateResult, err = coll.UpdateOne(ctxObj, cond, updateSet, updateOneOpts)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -471,29 +638,44 @@ func (c *Collection) UpdateOneCustom(ctx context.Context,
 }
 
 func (c *Collection) UpdateManyCustom(ctx context.Context,
-	filter *Query, update updateType, updateDoc map[string]interface{}, opts *Update) (*UpdateResult, error) {
+	filter *Query, update updateType, updateDoc map[string]interface{}, opts *Update) (ret *UpdateResult, err error) {
+	ctxObj, end, err := c.start(ctx, "UpdateManyCustom")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
 	if updateDoc == nil {
-		return nil, fmt.Errorf("updateDoc is not nil")
+		return nil, fmt.Errorf("%w: updateDoc is not nil", ErrInvalidQuery)
 	}
 
 	if updateDoc == nil {
-		return nil, fmt.Errorf("updateDoc is not nil")
+		return nil, fmt.Errorf("%w: updateDoc is not nil", ErrInvalidQuery)
 	}
 
 	updateSet := bson.M{update.String(): updateDoc}
 
+	coll := c.collection
 	updateManyOpts := options.Update()
 	if opts != nil {
+		if coll, err = c.withConcern(nil, &opts.BasicWriteOptions); err != nil {
+			return nil, err
+		}
+
 		if opts.upsert != nil {
 			updateManyOpts.SetUpsert(*opts.upsert)
 		}
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
 	}
-	updateResult, err := c.collection.UpdateMany(ctxObj, filter.Cond(), updateSet, updateManyOpts)
+	var updateResult *mongo.UpdateResult
+	err = c.retry(ctxObj, "UpdateManyCustom", idempotentUpdate(updateSet), func() (err error) {
+		updateResult, err = coll.UpdateMany(ctxObj, cond, updateSet, updateManyOpts)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -507,23 +689,42 @@ func (c *Collection) UpdateManyCustom(ctx context.Context,
 }
 
 func (c *Collection) ReplaceOne(ctx context.Context,
-	filter *Query, replaceDoc map[string]interface{}, opts *Replace) (*UpdateResult, error) {
+	filter *Query, replaceDoc map[string]interface{}, opts *Replace) (ret *UpdateResult, err error) {
+	ctxObj, end, err := c.start(ctx, "ReplaceOne")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
 	if replaceDoc == nil {
-		return nil, fmt.Errorf("upDoc is not nil")
+		return nil, fmt.Errorf("%w: upDoc is not nil", ErrInvalidQuery)
 	}
 
+	coll := c.collection
 	replaceOpts := options.Replace()
 	if opts != nil {
+		if coll, err = c.withConcern(nil, &opts.BasicWriteOptions); err != nil {
+			return nil, err
+		}
+
 		if opts.upsert != nil {
 			replaceOpts.SetUpsert(*opts.upsert)
 		}
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
+	}
+	replaceDoc, err = c.stampTenant(ctxObj, replaceDoc)
+	if err != nil {
+		return nil, err
 	}
-	updateResult, err := c.collection.ReplaceOne(ctxObj, filter.Cond(), replaceDoc, replaceOpts)
+	var updateResult *mongo.UpdateResult
+	err = c.retry(ctxObj, "ReplaceOne", true, func() (err error) {
+		updateResult, err = coll.ReplaceOne(ctxObj, cond, replaceDoc, replaceOpts)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -536,12 +737,24 @@ func (c *Collection) ReplaceOne(ctx context.Context,
 	}, nil
 }
 
-func (c *Collection) DeleteOne(ctx context.Context, filter *Query) (*DeleteResult, error) {
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+func (c *Collection) DeleteOne(ctx context.Context, filter *Query, opts ...*DeleteOptions) (ret *DeleteResult, err error) {
+	ctxObj, end, err := c.start(ctx, "DeleteOne")
+	if err != nil {
+		return nil, err
 	}
-	delResult, err := c.collection.DeleteOne(ctxObj, filter.Cond())
+	defer end(&err)
+
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
+	}
+	coll := c.collection
+	if op := lastOption(opts); op != nil {
+		if coll, err = c.withConcern(nil, &op.BasicWriteOptions); err != nil {
+			return nil, err
+		}
+	}
+	delResult, err := coll.DeleteOne(ctxObj, cond)
 	if err != nil {
 		return nil, err
 	}
@@ -551,12 +764,28 @@ func (c *Collection) DeleteOne(ctx context.Context, filter *Query) (*DeleteResul
 	}, nil
 }
 
-func (c *Collection) DeleteMany(ctx context.Context, filter *Query) (*DeleteResult, error) {
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+func (c *Collection) DeleteMany(ctx context.Context, filter *Query, opts ...*DeleteOptions) (ret *DeleteResult, err error) {
+	ctxObj, end, err := c.start(ctx, "DeleteMany")
+	if err != nil {
+		return nil, err
 	}
-	delResult, err := c.collection.DeleteMany(ctxObj, filter.Cond())
+	defer end(&err)
+
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
+	}
+	coll := c.collection
+	if op := lastOption(opts); op != nil {
+		if coll, err = c.withConcern(nil, &op.BasicWriteOptions); err != nil {
+			return nil, err
+		}
+	}
+	var delResult *mongo.DeleteResult
+	err = c.retry(ctxObj, "DeleteMany", true, func() (err error) {
+		delResult, err = coll.DeleteMany(ctxObj, cond)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -566,9 +795,17 @@ func (c *Collection) DeleteMany(ctx context.Context, filter *Query) (*DeleteResu
 	}, nil
 }
 
-func (c *Collection) BulkWrite(ctx context.Context, bwm *BulkWriteModel) (*BulkWriteResult, error) {
+// BulkWrite 批量写入，按 SetChunkSize 分块，结果为所有块的汇总
+// 存在失败的操作时返回 *ErrBulkPartial 与已执行部分的结果，Failures 中为失败操作的下标、错误码、错误信息与原始操作
+func (c *Collection) BulkWrite(ctx context.Context, bwm *BulkWriteModel) (ret *BulkWriteResult, err error) {
+	ctxObj, end, err := c.start(ctx, "BulkWrite")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
 	if len(bwm.models) <= 0 {
-		return nil, fmt.Errorf("BulkWriteModel models's length is 0")
+		return nil, fmt.Errorf("%w: BulkWriteModel models's length is 0", ErrInvalidQuery)
 	}
 
 	bulkWriteOpts := options.BulkWrite()
@@ -576,48 +813,79 @@ func (c *Collection) BulkWrite(ctx context.Context, bwm *BulkWriteModel) (*BulkW
 		bulkWriteOpts.SetOrdered(*bwm.ordered)
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+	models, err := c.tenantModels(ctxObj, bwm.models)
+	if err != nil {
+		return nil, err
 	}
-	bulkWriteResults, err := c.collection.BulkWrite(ctxObj, bwm.models, bulkWriteOpts)
+	chunks := bwm.split(models)
+	coll, err := c.withConcern(nil, &bwm.BasicWriteOptions)
 	if err != nil {
 		return nil, err
 	}
-
-	return &BulkWriteResult{
-		InsertedCount: bulkWriteResults.InsertedCount,
-		MatchedCount:  bulkWriteResults.MatchedCount,
-		ModifiedCount: bulkWriteResults.ModifiedCount,
-		DeletedCount:  bulkWriteResults.DeletedCount,
-		UpsertedCount: bulkWriteResults.UpsertedCount,
-		UpsertedIDs:   bulkWriteResults.UpsertedIDs,
-	}, nil
+	bwm.writeChunks(ctxObj, coll, chunks, bulkWriteOpts)
+	return bwm.merge(chunks)
 }
 
+// Distinct 字段去重查询，serverMaxTime 为 nil 时默认 5s
 func (c *Collection) Distinct(ctx context.Context, fieldName string,
 	filter *Query, serverMaxTime *time.Duration) ([]interface{}, error) {
-	distinctOpts := options.Distinct()
+	opts := NewDistinctOptions()
 	if serverMaxTime == nil {
-		distinctOpts.SetMaxTime(5 * time.Second)
+		opts.MaxTime(5 * time.Second)
 	} else {
-		distinctOpts.SetMaxTime(*serverMaxTime)
+		opts.MaxTime(*serverMaxTime)
+	}
+	return c.DistinctWithOptions(ctx, fieldName, filter, opts)
+}
+
+// DistinctWithOptions 字段去重查询，支持读偏好、超时、排序规则等选项
+func (c *Collection) DistinctWithOptions(ctx context.Context, fieldName string,
+	filter *Query, opts *DistinctOptions) (ret []interface{}, err error) {
+	ctxObj, end, err := c.start(ctx, "Distinct")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	coll := c.collection
+	distinctOpts := options.Distinct()
+	if opts != nil {
+		if coll, err = c.withConcern(&opts.BasicReadOptions, nil); err != nil {
+			return nil, err
+		}
+		opts.applyDistinct(distinctOpts)
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
 	}
-	distinctValues, err := c.collection.Distinct(ctxObj, fieldName, filter.Cond(), distinctOpts)
+	var distinctValues []interface{}
+	err = c.retry(ctxObj, "Distinct", true, func() (err error) {
+		distinctValues, err = coll.Distinct(ctxObj, fieldName, cond, distinctOpts)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 	return distinctValues, nil
 }
 
-func (c *Collection) Count(ctx context.Context, filter *Query, opts *CountOptions) (int64, error) {
+func (c *Collection) Count(ctx context.Context, filter *Query, opts *CountOptions) (ret int64, err error) {
+	ctxObj, end, err := c.start(ctx, "Count")
+	if err != nil {
+		return 0, err
+	}
+	defer end(&err)
+
+	coll := c.collection
 	countOpts := options.Count()
 	if opts != nil {
+		if coll, err = c.withConcern(&opts.BasicReadOptions, nil); err != nil {
+			return 0, err
+		}
+		opts.applyCount(countOpts)
+
 		if opts.limit != nil {
 			countOpts.SetLimit(*opts.limit)
 		}
@@ -626,37 +894,76 @@ func (c *Collection) Count(ctx context.Context, filter *Query, opts *CountOption
 		}
 	}
 
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return 0, err
 	}
-	count, err := c.collection.CountDocuments(ctxObj, filter.Cond(), countOpts)
+	var count int64
+	err = c.retry(ctxObj, "Count", true, func() (err error) {
+		count, err = coll.CountDocuments(ctxObj, cond, countOpts)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
 	return count, nil
 }
 
+// Aggregate 聚合查询，serverMaxTime 为 nil 时默认 3s
 func (c *Collection) Aggregate(ctx context.Context, results interface{}, serverMaxTime *time.Duration, pipeline ...interface{}) error {
-	ctxObj := c.ctx
-	if ctx != nil {
-		ctxObj = ctx
-	}
-
-	opts := options.Aggregate()
+	opts := NewAggregateOptions()
 	if serverMaxTime == nil {
-		opts.SetMaxTime(3 * time.Second)
+		opts.MaxTime(3 * time.Second)
 	} else {
-		opts.SetMaxTime(*serverMaxTime)
+		opts.MaxTime(*serverMaxTime)
 	}
+	return c.AggregateWithOptions(ctx, results, opts, pipeline...)
+}
 
-	aggCursor, err := c.collection.Aggregate(ctxObj, pipeline, opts)
+// AggregateWithOptions 聚合查询，支持读偏好、读写关注等选项
+func (c *Collection) AggregateWithOptions(ctx context.Context, results interface{}, opts *AggregateOptions, pipeline ...interface{}) (err error) {
+	ctxObj, end, err := c.start(ctx, "Aggregate")
 	if err != nil {
 		return err
 	}
+	defer end(&err)
 
-	if err = aggCursor.All(ctx, results); err != nil {
+	coll := c.collection
+	aggOpts := options.Aggregate()
+	if opts != nil {
+		if coll, err = c.withConcern(&opts.BasicReadOptions, &opts.BasicWriteOptions); err != nil {
+			return err
+		}
+		opts.applyAggregate(aggOpts)
+	}
+
+	pipeline, err = c.tenantPipeline(ctxObj, pipeline)
+	if err != nil {
 		return err
 	}
-	return nil
+	return c.retry(ctxObj, "Aggregate", !pipelineWrites(pipeline), func() error {
+		aggCursor, err := coll.Aggregate(ctxObj, pipeline, aggOpts)
+		if err != nil {
+			return err
+		}
+		return aggCursor.All(ctxObj, results)
+	})
+}
+
+// decodeSingle 解码单条结果，doc 为 nil 时只检查错误
+// 未找到文档时 errorOnMissing 为 true 返回 ErrNotFound，否则返回 nil
+func decodeSingle(res *mongo.SingleResult, doc interface{}, errorOnMissing bool) error {
+	var err error
+	if doc == nil {
+		err = res.Err()
+	} else {
+		err = res.Decode(doc)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if errorOnMissing {
+			return wrapError(err)
+		}
+		return nil
+	}
+	return err
 }
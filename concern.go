@@ -0,0 +1,286 @@
+// Package mongo
+package mongo
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/tag"
+)
+
+// 读关注级别
+const (
+	ReadConcernLocal        = "local"
+	ReadConcernAvailable    = "available"
+	ReadConcernMajority     = "majority"
+	ReadConcernLinearizable = "linearizable"
+	ReadConcernSnapshot     = "snapshot"
+)
+
+// WriteConcern 写关注
+// W：写入确认的节点，"majority"、tag 名称或节点数量（int），nil 使用服务端默认值
+// J：是否等待写入日志，WTimeout：等待确认的超时时间
+type WriteConcern struct {
+	W        interface{}
+	J        bool
+	WTimeout time.Duration
+}
+
+func (wc WriteConcern) driver() (*writeconcern.WriteConcern, error) {
+	var opts []writeconcern.Option
+	switch w := wc.W.(type) {
+	case nil:
+	case int:
+		if w < 0 {
+			return nil, fmt.Errorf("%w: write concern w[%d] must be gte 0", ErrInvalidQuery, w)
+		}
+		opts = append(opts, writeconcern.W(w))
+	case string:
+		if w == "" {
+			return nil, fmt.Errorf("%w: write concern w can not be empty", ErrInvalidQuery)
+		}
+		if w == "majority" {
+			opts = append(opts, writeconcern.WMajority())
+		} else {
+			opts = append(opts, writeconcern.WTagSet(w))
+		}
+	default:
+		return nil, fmt.Errorf("%w: write concern w[%v] must be int or string", ErrInvalidQuery, wc.W)
+	}
+	if wc.J {
+		opts = append(opts, writeconcern.J(true))
+	}
+	if wc.WTimeout > 0 {
+		opts = append(opts, writeconcern.WTimeout(wc.WTimeout))
+	}
+	return writeconcern.New(opts...), nil
+}
+
+// newReadPref 创建读偏好，mode：primary、primaryPreferred、secondary、secondaryPreferred、nearest
+// tags 节点标签，按顺序匹配，如：{"dc": "ny"}, {}
+func newReadPref(mode string, tags ...map[string]string) (*readpref.ReadPref, error) {
+	m, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read preference mode[%s] is invalid", ErrInvalidQuery, mode)
+	}
+
+	var opts []readpref.Option
+	if len(tags) > 0 {
+		tagSets := make([]tag.Set, 0, len(tags))
+		for _, tags := range tags {
+			tagSet := tag.Set{}
+			for k, v := range tags {
+				tagSet = append(tagSet, tag.Tag{Name: k, Value: v})
+			}
+			tagSets = append(tagSets, tagSet)
+		}
+		opts = append(opts, readpref.WithTagSets(tagSets...))
+	}
+
+	rp, err := readpref.New(m, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read preference: %s", ErrInvalidQuery, err.Error())
+	}
+	return rp, nil
+}
+
+// BasicReadOptions 读偏好、读关注，未设置时使用集合默认值
+type BasicReadOptions struct {
+	readPref    *readpref.ReadPref
+	readConcern *readconcern.ReadConcern
+	// err 无效的读偏好，使用该选项的操作返回此错误
+	err error
+}
+
+// ReadPreference 设置读偏好，如：ReadPreference("secondaryPreferred", map[string]string{"dc": "ny"})
+// mode 无效时使用该选项的操作返回 ErrInvalidQuery
+func (op *BasicReadOptions) ReadPreference(mode string, tags ...map[string]string) *BasicReadOptions {
+	op.readPref, op.err = newReadPref(mode, tags...)
+	return op
+}
+
+// ReadConcern 设置读关注，如：ReadConcernMajority
+func (op *BasicReadOptions) ReadConcern(level string) *BasicReadOptions {
+	op.readConcern = readconcern.New(readconcern.Level(level))
+	return op
+}
+
+// BasicWriteOptions 写关注，未设置时使用集合默认值
+type BasicWriteOptions struct {
+	writeConcern *writeconcern.WriteConcern
+	// err 无效的写关注，使用该选项的操作返回此错误
+	err error
+}
+
+// WriteConcern 设置写关注，如：WriteConcern{W: "majority", J: true}
+// W 无效时使用该选项的操作返回 ErrInvalidQuery
+func (op *BasicWriteOptions) WriteConcern(wc WriteConcern) *BasicWriteOptions {
+	op.writeConcern, op.err = wc.driver()
+	return op
+}
+
+// CollectionOptions 数据库、集合的默认读写选项
+type CollectionOptions struct {
+	BasicReadOptions
+	BasicWriteOptions
+}
+
+// NewCollectionOptions 创建数据库、集合默认读写选项
+func NewCollectionOptions() *CollectionOptions {
+	op := new(CollectionOptions)
+	return op
+}
+
+// ReadPreference 设置默认读偏好
+func (op *CollectionOptions) ReadPreference(mode string, tags ...map[string]string) *CollectionOptions {
+	op.BasicReadOptions.ReadPreference(mode, tags...)
+	return op
+}
+
+// ReadConcern 设置默认读关注
+func (op *CollectionOptions) ReadConcern(level string) *CollectionOptions {
+	op.BasicReadOptions.ReadConcern(level)
+	return op
+}
+
+// WriteConcern 设置默认写关注
+func (op *CollectionOptions) WriteConcern(wc WriteConcern) *CollectionOptions {
+	op.BasicWriteOptions.WriteConcern(wc)
+	return op
+}
+
+func (op *CollectionOptions) merge(other *CollectionOptions) *CollectionOptions {
+	newOp := new(CollectionOptions)
+	if op != nil {
+		*newOp = *op
+	}
+	if other.readPref != nil || other.BasicReadOptions.err != nil {
+		newOp.readPref, newOp.BasicReadOptions.err = other.readPref, other.BasicReadOptions.err
+	}
+	if other.readConcern != nil {
+		newOp.readConcern = other.readConcern
+	}
+	if other.writeConcern != nil || other.BasicWriteOptions.err != nil {
+		newOp.writeConcern, newOp.BasicWriteOptions.err = other.writeConcern, other.BasicWriteOptions.err
+	}
+	return newOp
+}
+
+func concernOptions(r *BasicReadOptions, w *BasicWriteOptions) *options.CollectionOptions {
+	opts := options.Collection()
+	if r != nil {
+		if r.readPref != nil {
+			opts.SetReadPreference(r.readPref)
+		}
+		if r.readConcern != nil {
+			opts.SetReadConcern(r.readConcern)
+		}
+	}
+	if w != nil && w.writeConcern != nil {
+		opts.SetWriteConcern(w.writeConcern)
+	}
+	return opts
+}
+
+// concernErr 读写选项中的错误
+func concernErr(r *BasicReadOptions, w *BasicWriteOptions) error {
+	if r != nil && r.err != nil {
+		return r.err
+	}
+	if w != nil && w.err != nil {
+		return w.err
+	}
+	return nil
+}
+
+// withConcern 返回使用操作读写选项的driver集合，未设置时返回集合本身
+func (c *Collection) withConcern(r *BasicReadOptions, w *BasicWriteOptions) (*mongo.Collection, error) {
+	if err := concernErr(r, w); err != nil {
+		return nil, err
+	}
+	if (r == nil || (r.readPref == nil && r.readConcern == nil)) && (w == nil || w.writeConcern == nil) {
+		return c.collection, nil
+	}
+
+	return c.collection.Clone(concernOptions(r, w))
+}
+
+// WithOptions 返回使用指定默认读写选项的集合副本，原集合不变
+// 选项无效时副本的操作返回 ErrInvalidQuery
+func (c *Collection) WithOptions(opts *CollectionOptions) *Collection {
+	newC := *c
+	if opts != nil {
+		coll, err := c.withConcern(&opts.BasicReadOptions, &opts.BasicWriteOptions)
+		if err != nil {
+			newC.optsErr = err
+		} else {
+			newC.collection = coll
+		}
+	}
+	return &newC
+}
+
+// WithOptions 返回使用指定默认读写选项的数据库副本，由副本创建的 Collection、ORM 均使用该默认值
+// 选项无效时副本创建的集合的操作返回 ErrInvalidQuery
+func (db *Database) WithOptions(opts *CollectionOptions) *Database {
+	newDB := *db
+	if opts == nil {
+		return &newDB
+	}
+	if err := concernErr(&opts.BasicReadOptions, &opts.BasicWriteOptions); err != nil {
+		newDB.optsErr = err
+		return &newDB
+	}
+
+	dbOpts := options.Database().
+		SetReadPreference(db.db.ReadPreference()).
+		SetReadConcern(db.db.ReadConcern()).
+		SetWriteConcern(db.db.WriteConcern())
+	if opts.readPref != nil {
+		dbOpts.SetReadPreference(opts.readPref)
+	}
+	if opts.readConcern != nil {
+		dbOpts.SetReadConcern(opts.readConcern)
+	}
+	if opts.writeConcern != nil {
+		dbOpts.SetWriteConcern(opts.writeConcern)
+	}
+	newDB.db = db.mongoClient.Database(db.dbName, dbOpts)
+	return &newDB
+}
+
+// ReadPreference 设置本ORM的读偏好，如：ReadPreference("secondary") 将分析查询发送到从节点
+func (orm *ORM) ReadPreference(mode string, tags ...map[string]string) *ORM {
+	return orm.withConcern(NewCollectionOptions().ReadPreference(mode, tags...))
+}
+
+// ReadConcern 设置本ORM的读关注
+func (orm *ORM) ReadConcern(level string) *ORM {
+	return orm.withConcern(NewCollectionOptions().ReadConcern(level))
+}
+
+// WriteConcern 设置本ORM的写关注，如：WriteConcern{W: "majority", J: true}
+func (orm *ORM) WriteConcern(wc WriteConcern) *ORM {
+	return orm.withConcern(NewCollectionOptions().WriteConcern(wc))
+}
+
+func (orm *ORM) withConcern(op *CollectionOptions) *ORM {
+	o := orm.builder()
+	o.concern = o.concern.merge(op)
+	return o
+}
+
+// lastOption 可变参数中最后一个非nil的选项
+func lastOption[T any](opts []*T) *T {
+	for i := len(opts) - 1; i >= 0; i-- {
+		if opts[i] != nil {
+			return opts[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestConcern(t *testing.T) {
+	cli, err := NewClient(context.Background(), OptionsFromURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close(context.Background())
+
+	db := cli.Database("test")
+	analytics := db.WithOptions(NewCollectionOptions().ReadPreference("secondaryPreferred").WriteConcern(WriteConcern{W: 2}))
+	if analytics.db.ReadPreference().Mode() != readpref.SecondaryPreferredMode || analytics.db.WriteConcern().GetW() != 2 {
+		t.Fatal("database options error")
+	}
+	if db.db.ReadPreference().Mode() != readpref.PrimaryMode {
+		t.Fatal("origin database must not be changed")
+	}
+
+	orm := NewORMByDB(context.Background(), db, "user", nil).
+		ReadPreference("nearest", map[string]string{"dc": "ny"}).
+		WriteConcern(WriteConcern{W: "majority", J: true})
+	if orm.concern.readPref.Mode() != readpref.NearestMode || len(orm.concern.readPref.TagSets()) != 1 {
+		t.Fatal("orm read preference error")
+	}
+	if wc := orm.concern.writeConcern; wc.GetW() != "majority" || !wc.GetJ() {
+		t.Fatal("orm write concern error")
+	}
+
+	table := db.Collection("user")
+	opts := NewFindOptions()
+	opts.ReadPreference("secondary")
+	if coll, err := table.withConcern(&opts.BasicReadOptions, nil); err != nil || coll == table.collection {
+		t.Fatal("find read preference error")
+	}
+	if coll, err := table.withConcern(&NewFindOptions().BasicReadOptions, nil); err != nil || coll != table.collection {
+		t.Fatal("empty options must use collection defaults")
+	}
+
+	// 无效的读偏好、写关注由操作返回错误
+	count := NewCount()
+	count.ReadPreference("any")
+	if _, err = table.Count(context.Background(), nil, count); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("invalid read preference must be ErrInvalidQuery, got %v", err)
+	}
+	if _, err = orm.WriteConcern(WriteConcern{W: -1}).DeleteOne(); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("invalid write concern must be ErrInvalidQuery, got %v", err)
+	}
+	if _, err = db.WithOptions(NewCollectionOptions().ReadPreference("any")).Collection("user").Count(context.Background(), nil, nil); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("invalid database options must be ErrInvalidQuery, got %v", err)
+	}
+}
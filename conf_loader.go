@@ -0,0 +1,356 @@
+// Package mongo
+package mongo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const redacted = "******"
+
+// String 配置字符串，密码等敏感信息会被隐藏
+func (c Conf) String() string {
+	if c.Pass != "" {
+		c.Pass = redacted
+	}
+	if c.TLSCertKeyPassword != "" {
+		c.TLSCertKeyPassword = redacted
+	}
+	bs, err := json.Marshal(c)
+	if err != nil {
+		return err.Error()
+	}
+	return string(bs)
+}
+
+// ConfFromFile 从 json 文件加载配置
+// yaml、toml 文件使用独立的模块 github.com/assembly-hub/mongo/mongoconf 的 FromFile，主模块不依赖 yaml、toml 解析库
+func ConfFromFile(path string) (*Conf, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.ToLower(filepath.Ext(path)) != ".json" {
+		return nil, fmt.Errorf("mongo conf: file[%s] type not supported, use github.com/assembly-hub/mongo/mongoconf FromFile for yaml or toml", path)
+	}
+	conf := new(Conf)
+	if err = json.Unmarshal(bs, conf); err != nil {
+		return nil, fmt.Errorf("mongo conf: file[%s] parse error: %w", path, err)
+	}
+
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// ConfFromEnv 从环境变量加载配置，变量名：前缀_字段json名大写，如：MONGO_HOSTS、MONGO_MAX_POOL_SIZE
+// 前缀为空时使用 MONGO，避免读取 USER、DB 等无关的环境变量
+// 前缀_URI 存在时先按连接串解析，其他变量覆盖对应的字段
+// 列表以逗号分隔，read_preference_tags 以分号分隔多组，如：dc:ny,rack:1;dc:sf
+// options 为连接串参数格式，如：appName=svc&loadBalanced=true
+func ConfFromEnv(prefix string) (*Conf, error) {
+	if prefix == "" {
+		prefix = "MONGO"
+	}
+	if !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	conf := new(Conf)
+	if uri, ok := os.LookupEnv(prefix + "URI"); ok && uri != "" {
+		c, err := parseURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		conf = c
+	}
+
+	confErr := new(ConfError)
+	v := reflect.ValueOf(conf).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		val, ok := os.LookupEnv(prefix + strings.ToUpper(name))
+		if !ok {
+			continue
+		}
+		if err := setEnvField(v.Field(i), strings.TrimSpace(val)); err != nil {
+			confErr.add(name, "env[%s] %s", prefix+strings.ToUpper(name), err.Error())
+		}
+	}
+
+	if err := conf.Validate(); err != nil {
+		var validateErr *ConfError
+		if errors.As(err, &validateErr) {
+			confErr.Errors = append(confErr.Errors, validateErr.Errors...)
+		}
+	}
+	if err := confErr.orNil(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func setEnvField(field reflect.Value, val string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(val)
+	case bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("[%s] must be bool", val)
+		}
+		field.SetBool(b)
+	case *bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("[%s] must be bool", val)
+		}
+		field.Set(reflect.ValueOf(&b))
+	case int:
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("[%s] must be int", val)
+		}
+		field.SetInt(int64(n))
+	case uint64:
+		n, err := strconv.ParseUint(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("[%s] must be uint", val)
+		}
+		field.SetUint(n)
+	case []string:
+		field.Set(reflect.ValueOf(splitList(val, ",")))
+	case []map[string]string:
+		var tagSets []map[string]string
+		for _, s := range strings.Split(val, ";") {
+			tags, err := parseTags(strings.TrimSpace(s))
+			if err != nil {
+				return err
+			}
+			tagSets = append(tagSets, tags)
+		}
+		field.Set(reflect.ValueOf(tagSets))
+	case map[string]string:
+		params, err := url.ParseQuery(val)
+		if err != nil {
+			return fmt.Errorf("[%s] must be key=value&key=value", val)
+		}
+		m := map[string]string{}
+		for k, values := range params {
+			m[k] = values[len(values)-1]
+		}
+		field.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("type[%s] not supported", field.Type())
+	}
+	return nil
+}
+
+// ConfFromURI 从连接串加载配置，与 Conf.URI 生成的连接串互相转换
+func ConfFromURI(uri string) (*Conf, error) {
+	conf, err := parseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func parseURI(uri string) (*Conf, error) {
+	conf := new(Conf)
+	confErr := new(ConfError)
+
+	var rest string
+	switch {
+	case strings.HasPrefix(uri, "mongodb://"):
+		rest = strings.TrimPrefix(uri, "mongodb://")
+	case strings.HasPrefix(uri, "mongodb+srv://"):
+		conf.SRV = true
+		rest = strings.TrimPrefix(uri, "mongodb+srv://")
+	default:
+		confErr.add("uri", "scheme must be mongodb or mongodb+srv")
+		return nil, confErr
+	}
+
+	rawQuery := ""
+	if i := strings.Index(rest, "?"); i >= 0 {
+		rest, rawQuery = rest[:i], rest[i+1:]
+	}
+
+	if i := strings.Index(rest, "/"); i >= 0 {
+		db, err := url.PathUnescape(rest[i+1:])
+		if err != nil {
+			confErr.add("db", "unescape error: %s", err.Error())
+		}
+		rest, conf.DB = rest[:i], db
+	}
+
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		userInfo := rest[:i]
+		rest = rest[i+1:]
+
+		user, pass, _ := strings.Cut(userInfo, ":")
+		var err error
+		if conf.User, err = url.QueryUnescape(user); err != nil {
+			confErr.add("user", "unescape error: %s", err.Error())
+		}
+		if conf.Pass, err = url.QueryUnescape(pass); err != nil {
+			confErr.add("pass", "unescape error: %s", err.Error())
+		}
+	}
+	conf.Hosts = splitList(rest, ",")
+
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		confErr.add("uri", "query error: %s", err.Error())
+		return nil, confErr
+	}
+
+	for key, values := range params {
+		val := values[len(values)-1]
+		switch strings.ToLower(key) {
+		case "serverselectiontimeoutms":
+			conf.ServerSelectionTimeoutMS = uriInt(confErr, "server_selection_timeout_ms", val)
+		case "connecttimeoutms":
+			conf.ConnectTimeoutMS = uriInt(confErr, "connect_timeout_ms", val)
+		case "sockettimeoutms":
+			conf.SocketTimeoutMS = uriInt(confErr, "socket_timeout_ms", val)
+		case "heartbeatfrequencyms":
+			conf.HeartbeatIntervalMS = uriInt(confErr, "heartbeat_interval_ms", val)
+		case "authmechanism":
+			conf.AuthMechanism = val
+		case "authsource":
+			conf.AuthDB = val
+		case "replicaset":
+			conf.ReplicaSet = val
+		case "directconnection", "connect":
+			if key == "connect" {
+				val = strconv.FormatBool(val == "direct")
+			}
+			conf.Direct = uriBool(confErr, "direct", val)
+		case "tls", "ssl":
+			if b := uriBool(confErr, "tls", val); b != nil {
+				conf.TLS = *b
+			}
+		case "tlscafile":
+			conf.TLSCAFile = val
+		case "tlscertificatekeyfile":
+			conf.TLSCertKeyFile = val
+		case "tlscertificatekeyfilepassword":
+			conf.TLSCertKeyPassword = val
+		case "tlsinsecure":
+			if b := uriBool(confErr, "tls_insecure", val); b != nil {
+				conf.TLSInsecure = *b
+			}
+		case "compressors":
+			conf.Compressors = splitList(val, ",")
+		case "minpoolsize":
+			conf.MinPoolSize = uint64(uriInt(confErr, "min_pool_size", val))
+		case "maxpoolsize":
+			conf.MaxPoolSize = uint64(uriInt(confErr, "max_pool_size", val))
+		case "maxidletimems":
+			conf.MaxIdleTimeMS = uriInt(confErr, "max_idle_time_ms", val)
+		case "readpreference":
+			conf.ReadPreference = val
+		case "readpreferencetags":
+			for _, v := range values {
+				tags, err := parseTags(v)
+				if err != nil {
+					confErr.add("read_preference_tags", err.Error())
+					continue
+				}
+				conf.ReadPreferenceTags = append(conf.ReadPreferenceTags, tags)
+			}
+		case "maxstalenessseconds":
+			conf.MaxStalenessSeconds = uriInt(confErr, "max_staleness_seconds", val)
+		case "w":
+			conf.W = val
+		case "journal":
+			conf.Journal = uriBool(confErr, "journal", val)
+		case "wtimeoutms":
+			conf.WTimeoutMS = uriInt(confErr, "w_timeout_ms", val)
+		case "retrywrites":
+			conf.RetryWrites = uriBool(confErr, "retry_writes", val)
+		case "retryreads":
+			conf.RetryReads = uriBool(confErr, "retry_reads", val)
+		default:
+			// 其他参数原样保留，由驱动解析
+			if conf.Options == nil {
+				conf.Options = map[string]string{}
+			}
+			conf.Options[key] = val
+		}
+	}
+
+	if err = confErr.orNil(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// uriOptions parseURI 解析到字段的参数，小写
+var uriOptions = map[string]bool{
+	"serverselectiontimeoutms": true, "connecttimeoutms": true, "sockettimeoutms": true, "heartbeatfrequencyms": true,
+	"authmechanism": true, "authsource": true, "replicaset": true, "directconnection": true, "connect": true,
+	"tls": true, "ssl": true, "tlscafile": true, "tlscertificatekeyfile": true, "tlscertificatekeyfilepassword": true,
+	"tlsinsecure": true, "compressors": true, "minpoolsize": true, "maxpoolsize": true, "maxidletimems": true,
+	"readpreference": true, "readpreferencetags": true, "maxstalenessseconds": true, "w": true, "journal": true,
+	"wtimeoutms": true, "retrywrites": true, "retryreads": true,
+}
+
+func uriInt(confErr *ConfError, field, val string) int {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		confErr.add(field, "[%s] must be uint", val)
+		return 0
+	}
+	return n
+}
+
+func uriBool(confErr *ConfError, field, val string) *bool {
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		confErr.add(field, "[%s] must be bool", val)
+		return nil
+	}
+	return &b
+}
+
+// parseTags 解析标签：dc:ny,rack:1，空字符串为空标签
+func parseTags(s string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, pair := range splitList(s, ",") {
+		k, v, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("tag[%s] must be key:value", pair)
+		}
+		tags[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return tags, nil
+}
+
+func splitList(s, sep string) []string {
+	var arr []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			arr = append(arr, item)
+		}
+	}
+	return arr
+}
@@ -1,37 +1,329 @@
 // Package mongo
 package mongo
 
-import "go.mongodb.org/mongo-driver/mongo/options"
+import (
+	"fmt"
+	"net/url"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/assembly-hub/basics/util"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
 
 type Conf struct {
-	HostMaster               string
-	HostSlave                string
-	ReplicaSet               string
-	User                     string
-	Pass                     string
-	DB                       string
-	AuthDB                   string
-	AuthMechanism            string
-	ServerSelectionTimeoutMS int
-	ConnectTimeoutMS         int
-	Connect                  bool
+	// Hosts 节点地址列表，如：["127.0.0.1:27017", "127.0.0.2:27017"]，与 HostMaster、HostSlave 合并
+	Hosts      []string `json:"hosts" yaml:"hosts" toml:"hosts"`
+	HostMaster string   `json:"host_master" yaml:"host_master" toml:"host_master"`
+	HostSlave  string   `json:"host_slave" yaml:"host_slave" toml:"host_slave"`
+	// SRV 使用 mongodb+srv 协议，此时只能配置一个域名
+	SRV        bool   `json:"srv" yaml:"srv" toml:"srv"`
+	ReplicaSet string `json:"replica_set" yaml:"replica_set" toml:"replica_set"`
+	// Direct 是否直连，nil：单节点、非SRV且未配置副本集时直连
+	Direct *bool `json:"direct" yaml:"direct" toml:"direct"`
+
+	User          string `json:"user" yaml:"user" toml:"user"`
+	Pass          string `json:"pass" yaml:"pass" toml:"pass"`
+	DB            string `json:"db" yaml:"db" toml:"db"`
+	AuthDB        string `json:"auth_db" yaml:"auth_db" toml:"auth_db"`
+	AuthMechanism string `json:"auth_mechanism" yaml:"auth_mechanism" toml:"auth_mechanism"`
+
+	ServerSelectionTimeoutMS int `json:"server_selection_timeout_ms" yaml:"server_selection_timeout_ms" toml:"server_selection_timeout_ms"`
+	ConnectTimeoutMS         int `json:"connect_timeout_ms" yaml:"connect_timeout_ms" toml:"connect_timeout_ms"`
+	SocketTimeoutMS          int `json:"socket_timeout_ms" yaml:"socket_timeout_ms" toml:"socket_timeout_ms"`
+	HeartbeatIntervalMS      int `json:"heartbeat_interval_ms" yaml:"heartbeat_interval_ms" toml:"heartbeat_interval_ms"`
+
+	// TLS 配置
+	TLS                bool   `json:"tls" yaml:"tls" toml:"tls"`
+	TLSCAFile          string `json:"tls_ca_file" yaml:"tls_ca_file" toml:"tls_ca_file"`
+	TLSCertKeyFile     string `json:"tls_cert_key_file" yaml:"tls_cert_key_file" toml:"tls_cert_key_file"`
+	TLSCertKeyPassword string `json:"tls_cert_key_password" yaml:"tls_cert_key_password" toml:"tls_cert_key_password"`
+	TLSInsecure        bool   `json:"tls_insecure" yaml:"tls_insecure" toml:"tls_insecure"`
+
+	// Compressors 压缩方式：snappy、zlib、zstd
+	Compressors []string `json:"compressors" yaml:"compressors" toml:"compressors"`
+
+	// 连接池，MinPoolSize 默认 5，MaxPoolSize 默认 GOMAXPROCS * 5
+	MinPoolSize   uint64 `json:"min_pool_size" yaml:"min_pool_size" toml:"min_pool_size"`
+	MaxPoolSize   uint64 `json:"max_pool_size" yaml:"max_pool_size" toml:"max_pool_size"`
+	MaxIdleTimeMS int    `json:"max_idle_time_ms" yaml:"max_idle_time_ms" toml:"max_idle_time_ms"`
+
+	// ReadPreference 读偏好：primary、primaryPreferred、secondary、secondaryPreferred、nearest
+	ReadPreference string `json:"read_preference" yaml:"read_preference" toml:"read_preference"`
+	// ReadPreferenceTags 节点标签，按顺序匹配，如：[{"dc": "ny"}, {}]
+	ReadPreferenceTags  []map[string]string `json:"read_preference_tags" yaml:"read_preference_tags" toml:"read_preference_tags"`
+	MaxStalenessSeconds int                 `json:"max_staleness_seconds" yaml:"max_staleness_seconds" toml:"max_staleness_seconds"`
+
+	// 写关注，W：majority 或节点数量
+	W          string `json:"w" yaml:"w" toml:"w"`
+	Journal    *bool  `json:"journal" yaml:"journal" toml:"journal"`
+	WTimeoutMS int    `json:"w_timeout_ms" yaml:"w_timeout_ms" toml:"w_timeout_ms"`
+
+	RetryWrites *bool `json:"retry_writes" yaml:"retry_writes" toml:"retry_writes"`
+	RetryReads  *bool `json:"retry_reads" yaml:"retry_reads" toml:"retry_reads"`
+
+	// Options 其他连接串参数，原样写入连接串，如：{"appName": "svc", "loadBalanced": "true"}
+	// 已有字段的参数需要使用字段配置
+	Options map[string]string `json:"options" yaml:"options" toml:"options"`
+
+	// Connect 创建后是否 ping 检查连接
+	Connect bool `json:"connect" yaml:"connect" toml:"connect"`
+}
+
+var readPreferenceModes = []string{"primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest"}
+
+// hosts 合并后的节点地址
+func (c *Conf) hosts() []string {
+	var hosts []string
+	for _, h := range append([]string{c.HostMaster, c.HostSlave}, c.Hosts...) {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// ConfFieldError 配置字段错误
+type ConfFieldError struct {
+	Field string
+	Msg   string
+}
+
+// ConfError 配置错误，包含所有错误的字段
+type ConfError struct {
+	Errors []ConfFieldError
+}
+
+func (e *ConfError) add(field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, ConfFieldError{Field: field, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (e *ConfError) orNil() error {
+	if len(e.Errors) <= 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ConfError) Error() string {
+	arr := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		arr = append(arr, fieldErr.Field+": "+fieldErr.Msg)
+	}
+	return "mongo conf: " + strings.Join(arr, "; ")
+}
+
+// Validate 检查配置，返回 *ConfError，包含所有错误的字段
+func (c *Conf) Validate() error {
+	confErr := new(ConfError)
+	hosts := c.hosts()
+	if len(hosts) <= 0 {
+		confErr.add("hosts", "is empty")
+	}
+	if c.SRV && len(hosts) > 1 {
+		confErr.add("srv", "only supports one host")
+	}
+	if c.SRV && c.Direct != nil && *c.Direct {
+		confErr.add("direct", "srv can not be direct")
+	}
+	if c.DB == "" {
+		confErr.add("db", "is empty")
+	}
+	if c.Pass != "" && c.User == "" {
+		confErr.add("user", "is empty")
+	}
+	if c.MaxPoolSize > 0 && c.MinPoolSize > c.MaxPoolSize {
+		confErr.add("min_pool_size", "gt max pool size")
+	}
+	if c.ReadPreference != "" && !util.ElemIn(c.ReadPreference, readPreferenceModes) {
+		confErr.add("read_preference", "[%s] must be one of %s", c.ReadPreference, strings.Join(readPreferenceModes, ","))
+	}
+	if len(c.ReadPreferenceTags) > 0 && (c.ReadPreference == "" || c.ReadPreference == "primary") {
+		confErr.add("read_preference_tags", "can not be used with primary")
+	}
+	for _, compressor := range c.Compressors {
+		if !util.ElemIn(compressor, []string{"snappy", "zlib", "zstd"}) {
+			confErr.add("compressors", "[%s] must be snappy, zlib or zstd", compressor)
+		}
+	}
+	if c.W != "" && c.W != "majority" {
+		if n, err := strconv.Atoi(c.W); err != nil || n < 0 {
+			confErr.add("w", "[%s] must be majority or number", c.W)
+		}
+	}
+	for key := range c.Options {
+		if key == "" || uriOptions[strings.ToLower(key)] {
+			confErr.add("options", "[%s] must be set by field", key)
+		}
+	}
+	return confErr.orNil()
+}
+
+// URI 根据配置生成连接串，用户名、密码会被转义
+func (c *Conf) URI() (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+
+	hosts := c.hosts()
+	authDB := c.AuthDB
+	if authDB == "" {
+		authDB = c.DB
+	}
+
+	auth := ""
+	if c.User != "" {
+		auth = url.QueryEscape(c.User)
+		if c.Pass != "" {
+			auth += ":" + url.QueryEscape(c.Pass)
+		}
+
+		auth += "@"
+	}
+
+	params := url.Values{}
+	serverSelectionTimeoutMS := c.ServerSelectionTimeoutMS
+	if serverSelectionTimeoutMS <= 0 {
+		serverSelectionTimeoutMS = 5000
+	}
+	params.Set("serverSelectionTimeoutMS", strconv.Itoa(serverSelectionTimeoutMS))
+
+	connectTimeoutMS := c.ConnectTimeoutMS
+	if connectTimeoutMS <= 0 {
+		connectTimeoutMS = 10000
+	}
+	params.Set("connectTimeoutMS", strconv.Itoa(connectTimeoutMS))
+
+	setPositive(params, "socketTimeoutMS", c.SocketTimeoutMS)
+	setPositive(params, "heartbeatFrequencyMS", c.HeartbeatIntervalMS)
+
+	if c.User != "" {
+		authMechanism := c.AuthMechanism
+		if authMechanism == "" {
+			authMechanism = "SCRAM-SHA-1"
+		}
+		params.Set("authMechanism", authMechanism)
+		params.Set("authSource", authDB)
+	}
+
+	if c.ReplicaSet != "" {
+		params.Set("replicaSet", c.ReplicaSet)
+	}
+	if c.Direct != nil {
+		params.Set("directConnection", strconv.FormatBool(*c.Direct))
+	} else if len(hosts) == 1 && !c.SRV && c.ReplicaSet == "" {
+		params.Set("directConnection", "true")
+	}
+
+	if c.TLS {
+		params.Set("tls", "true")
+	}
+	if c.TLSCAFile != "" {
+		params.Set("tlsCAFile", c.TLSCAFile)
+	}
+	if c.TLSCertKeyFile != "" {
+		params.Set("tlsCertificateKeyFile", c.TLSCertKeyFile)
+	}
+	if c.TLSCertKeyPassword != "" {
+		params.Set("tlsCertificateKeyFilePassword", c.TLSCertKeyPassword)
+	}
+	if c.TLSInsecure {
+		params.Set("tlsInsecure", "true")
+	}
+
+	if len(c.Compressors) > 0 {
+		params.Set("compressors", strings.Join(c.Compressors, ","))
+	}
+
+	minPoolSize := c.MinPoolSize
+	if minPoolSize <= 0 {
+		minPoolSize = 5
+	}
+	maxPoolSize := c.MaxPoolSize
+	if maxPoolSize <= 0 {
+		maxPoolSize = uint64(runtime.GOMAXPROCS(0) * 5)
+		if maxPoolSize < minPoolSize {
+			maxPoolSize = minPoolSize
+		}
+	}
+	params.Set("minPoolSize", strconv.FormatUint(minPoolSize, 10))
+	params.Set("maxPoolSize", strconv.FormatUint(maxPoolSize, 10))
+	setPositive(params, "maxIdleTimeMS", c.MaxIdleTimeMS)
+
+	if c.ReadPreference != "" {
+		params.Set("readPreference", c.ReadPreference)
+	}
+	for _, tags := range c.ReadPreferenceTags {
+		params.Add("readPreferenceTags", formatTags(tags))
+	}
+	setPositive(params, "maxStalenessSeconds", c.MaxStalenessSeconds)
+
+	if c.W != "" {
+		params.Set("w", c.W)
+	}
+	if c.Journal != nil {
+		params.Set("journal", strconv.FormatBool(*c.Journal))
+	}
+	setPositive(params, "wtimeoutMS", c.WTimeoutMS)
+
+	if c.RetryWrites != nil {
+		params.Set("retryWrites", strconv.FormatBool(*c.RetryWrites))
+	}
+	if c.RetryReads != nil {
+		params.Set("retryReads", strconv.FormatBool(*c.RetryReads))
+	}
+	for key, val := range c.Options {
+		params.Set(key, val)
+	}
+
+	scheme := "mongodb"
+	if c.SRV {
+		scheme = "mongodb+srv"
+	}
+	return fmt.Sprintf("%s://%s%s/%s?%s",
+		scheme, auth, strings.Join(hosts, ","), url.PathEscape(c.DB), params.Encode()), nil
+}
+
+func setPositive(params url.Values, key string, v int) {
+	if v > 0 {
+		params.Set(key, strconv.Itoa(v))
+	}
+}
+
+// formatTags 标签格式：dc:ny,rack:1，按key排序
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+":"+tags[k])
+	}
+	return strings.Join(pairs, ",")
 }
 
 // ClientOptions 客户端配置
 type ClientOptions struct {
 	*options.ClientOptions
+	// codec 编码解码配置，见 RegisterCodec
+	codec *codecConf
 }
 
 // OptionsFromURI 根据uri创建配置
 func OptionsFromURI(uri string) *ClientOptions {
 	return &ClientOptions{
-		options.Client().ApplyURI(uri),
+		ClientOptions: options.Client().ApplyURI(uri),
 	}
 }
 
 // CreateEmptyOptions 创建空的配置
 func CreateEmptyOptions() *ClientOptions {
 	return &ClientOptions{
-		options.Client(),
+		ClientOptions: options.Client(),
 	}
 }
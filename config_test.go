@@ -0,0 +1,185 @@
+package mongo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestConfURI(t *testing.T) {
+	conf := &Conf{
+		Hosts:              []string{"127.0.0.1:27017", "127.0.0.2:27017"},
+		ReplicaSet:         "rs0",
+		User:               "user@test",
+		Pass:               "p@ss:w+rd/1",
+		DB:                 "test",
+		ReadPreference:     "secondaryPreferred",
+		ReadPreferenceTags: []map[string]string{{"dc": "ny", "rack": "1"}, {}},
+		Compressors:        []string{"zstd", "snappy"},
+		W:                  "majority",
+		MaxPoolSize:        20,
+	}
+
+	uri, err := conf.URI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(uri, "directConnection") {
+		t.Fatalf("multi hosts must not be direct: %s", uri)
+	}
+
+	opts := options.Client().ApplyURI(uri)
+	if err = opts.Validate(); err != nil {
+		t.Fatalf("uri[%s] error: %v", uri, err)
+	}
+	if opts.Auth.Username != conf.User || opts.Auth.Password != conf.Pass {
+		t.Fatalf("auth error: %s, %s", opts.Auth.Username, opts.Auth.Password)
+	}
+	if len(opts.Hosts) != 2 || *opts.ReplicaSet != "rs0" || *opts.MaxPoolSize != 20 {
+		t.Fatalf("options error: %v", uri)
+	}
+	if opts.ReadPreference.Mode().String() != "secondaryPreferred" || len(opts.ReadPreference.TagSets()) != 2 {
+		t.Fatalf("read preference error: %v", uri)
+	}
+}
+
+func TestConfValidate(t *testing.T) {
+	if _, err := (&Conf{DB: "test"}).URI(); err == nil {
+		t.Fatal("empty hosts must be error")
+	}
+	if _, err := (&Conf{HostMaster: "a", HostSlave: "b", SRV: true, DB: "test"}).URI(); err == nil {
+		t.Fatal("srv with multi hosts must be error")
+	}
+	if _, err := (&Conf{HostMaster: "a", DB: "test", ReadPreference: "any"}).URI(); err == nil {
+		t.Fatal("read preference must be error")
+	}
+
+	uri, err := (&Conf{HostMaster: "127.0.0.1:27017", DB: "test"}).URI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(uri, "directConnection=true") {
+		t.Fatalf("single host must be direct: %s", uri)
+	}
+}
+
+func TestConfFromURI(t *testing.T) {
+	conf := &Conf{
+		Hosts:              []string{"127.0.0.1:27017", "127.0.0.2:27017"},
+		ReplicaSet:         "rs0",
+		User:               "user@test",
+		Pass:               "p@ss:w+rd/1",
+		DB:                 "test",
+		ReadPreference:     "secondaryPreferred",
+		ReadPreferenceTags: []map[string]string{{"dc": "ny", "rack": "1"}, {}},
+		Compressors:        []string{"zstd", "snappy"},
+		W:                  "majority",
+		TLS:                true,
+		TLSCertKeyPassword: "secret",
+	}
+	uri, err := conf.URI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ConfFromURI(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri2, err := parsed.URI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri != uri2 {
+		t.Fatalf("uri not equal: %s != %s", uri, uri2)
+	}
+	if parsed.User != conf.User || parsed.Pass != conf.Pass {
+		t.Fatalf("auth error: %s, %s", parsed.User, parsed.Pass)
+	}
+
+	// 其他参数原样保留
+	parsed, err = ConfFromURI("mongodb://a/test?appName=svc&loadBalanced=true&authMechanismProperties=SERVICE_NAME:mongodb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Options["appName"] != "svc" || parsed.Options["authMechanismProperties"] != "SERVICE_NAME:mongodb" {
+		t.Fatalf("options error: %v", parsed.Options)
+	}
+	if uri, err = parsed.URI(); err != nil || !strings.Contains(uri, "loadBalanced=true") {
+		t.Fatalf("options uri error: %s, %v", uri, err)
+	}
+	if err = (&Conf{Hosts: []string{"a"}, DB: "test", Options: map[string]string{"maxPoolSize": "1"}}).Validate(); err == nil {
+		t.Fatal("option of field must be error")
+	}
+}
+
+func TestConfFromEnv(t *testing.T) {
+	t.Setenv("TEST_MONGO_HOSTS", "127.0.0.1:27017, 127.0.0.2:27017")
+	t.Setenv("TEST_MONGO_DB", "test")
+	t.Setenv("TEST_MONGO_MAX_POOL_SIZE", "20")
+	t.Setenv("TEST_MONGO_RETRY_WRITES", "false")
+	t.Setenv("TEST_MONGO_READ_PREFERENCE", "nearest")
+	t.Setenv("TEST_MONGO_READ_PREFERENCE_TAGS", "dc:ny,rack:1;")
+
+	t.Setenv("TEST_MONGO_OPTIONS", "appName=svc&localThresholdMS=20")
+
+	conf, err := ConfFromEnv("TEST_MONGO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Options["appName"] != "svc" || conf.Options["localThresholdMS"] != "20" {
+		t.Fatalf("options error: %v", conf.Options)
+	}
+	if len(conf.Hosts) != 2 || conf.DB != "test" || conf.MaxPoolSize != 20 {
+		t.Fatalf("conf error: %s", conf)
+	}
+	if conf.RetryWrites == nil || *conf.RetryWrites || len(conf.ReadPreferenceTags) != 2 {
+		t.Fatalf("conf error: %s", conf)
+	}
+}
+
+func TestConfError(t *testing.T) {
+	t.Setenv("BAD_MONGO_MAX_POOL_SIZE", "abc")
+	t.Setenv("BAD_MONGO_READ_PREFERENCE", "any")
+
+	_, err := ConfFromEnv("BAD_MONGO")
+	var confErr *ConfError
+	if !errors.As(err, &confErr) {
+		t.Fatalf("error must be ConfError: %v", err)
+	}
+
+	fields := map[string]bool{}
+	for _, fieldErr := range confErr.Errors {
+		fields[fieldErr.Field] = true
+	}
+	for _, field := range []string{"max_pool_size", "read_preference", "hosts", "db"} {
+		if !fields[field] {
+			t.Fatalf("field[%s] not in error: %v", field, err)
+		}
+	}
+}
+
+func TestConfString(t *testing.T) {
+	conf := Conf{HostMaster: "a", DB: "test", User: "user", Pass: "secret", TLSCertKeyPassword: "secret2"}
+	s := conf.String()
+	if strings.Contains(s, "secret") || !strings.Contains(s, "user") {
+		t.Fatalf("conf string error: %s", s)
+	}
+}
+
+func TestConfFromEnvDefaultPrefix(t *testing.T) {
+	t.Setenv("DB", "ambient")
+	t.Setenv("USER", "ambient")
+	t.Setenv("MONGO_HOSTS", "127.0.0.1:27017")
+	t.Setenv("MONGO_DB", "test")
+
+	conf, err := ConfFromEnv("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.DB != "test" || conf.User != "" {
+		t.Fatalf("empty prefix must use MONGO_: %s", conf)
+	}
+}
@@ -1,25 +1,43 @@
 // Package mongo
 package mongo
 
-import "go.mongodb.org/mongo-driver/mongo"
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
 
 type Database struct {
 	*Client
 
+	ctx    context.Context
 	dbName string
 	db     *mongo.Database
+	// optsErr WithOptions 的无效选项或多租户路由错误，由该数据库创建的集合的操作返回此错误
+	optsErr error
 }
 
 func (db *Database) Collection(name string) *Collection {
 	c := new(Collection)
 	c.Database = db
+	c.ctx = db.ctx
 	c.collectionName = name
 	c.collection = db.db.Collection(name)
+	c.optsErr = db.optsErr
 	return c
 }
 
 func (db *Database) TryCollection(name string) (c *Collection, exist bool, err error) {
-	names, err := db.db.ListCollectionNames(c.ctx, map[string]string{"name": name})
+	if db.optsErr != nil {
+		return nil, false, db.optsErr
+	}
+	done, err := db.begin(db.ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer done()
+
+	names, err := db.db.ListCollectionNames(db.ctx, map[string]string{"name": name})
 	if err != nil {
 		return nil, false, err
 	}
@@ -31,7 +49,17 @@ func (db *Database) TryCollection(name string) (c *Collection, exist bool, err e
 
 	c = new(Collection)
 	c.Database = db
+	c.ctx = db.ctx
 	c.collectionName = name
 	c.collection = db.db.Collection(name)
+	c.optsErr = db.optsErr
 	return c, exist, nil
 }
+
+// WithSession 返回绑定事务上下文的数据库副本
+// 由副本创建的 Collection、ORM 默认都在该事务中执行
+func (db *Database) WithSession(sessionCtx SessionContext) *Database {
+	newDB := *db
+	newDB.ctx = sessionCtx
+	return &newDB
+}
@@ -0,0 +1,140 @@
+// Package mongo
+package mongo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Decimal 保存为 Decimal128，JSON 序列化为字符串以保留精度，反序列化同时支持字符串与数字
+type Decimal primitive.Decimal128
+
+var (
+	tDecimal    = reflect.TypeOf(Decimal{})
+	tDecimal128 = reflect.TypeOf(primitive.Decimal128{})
+)
+
+// String2Decimal string 转 Decimal，如："12.34"、"-1E+3"
+func String2Decimal(v string) (Decimal, error) {
+	d, err := primitive.ParseDecimal128(v)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("decimal [%s] is invalid: %w", v, err)
+	}
+	return Decimal(d), nil
+}
+
+// TryString2Decimal string 转 Decimal，格式错误时 panic
+func TryString2Decimal(v string) Decimal {
+	d, err := String2Decimal(v)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Float2Decimal float64 转 Decimal，按最短的十进制表示转换
+func Float2Decimal(v float64) Decimal {
+	return TryString2Decimal(strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func (d Decimal) String() string {
+	return primitive.Decimal128(d).String()
+}
+
+// Float64 转 float64，可能丢失精度
+func (d Decimal) Float64() (float64, error) {
+	return strconv.ParseFloat(d.String(), 64)
+}
+
+// IsZero 是否为零值（未赋值）
+func (d Decimal) IsZero() bool {
+	return d == Decimal{}
+}
+
+// MarshalJSON 实现 json.Marshaler
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler，支持 "12.34"、12.34、null
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	s := string(b)
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	v, err := String2Decimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func decimalEncodeValue(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if !val.IsValid() || val.Type() != tDecimal {
+		return bsoncodec.ValueEncoderError{Name: "DecimalEncodeValue", Types: []reflect.Type{tDecimal}, Received: val}
+	}
+	return vw.WriteDecimal128(primitive.Decimal128(val.Interface().(Decimal)))
+}
+
+// decimalDecodeValue 支持 Decimal128、字符串、整数、浮点数
+func decimalDecodeValue(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	if !val.CanSet() || val.Type() != tDecimal {
+		return bsoncodec.ValueDecoderError{Name: "DecimalDecodeValue", Types: []reflect.Type{tDecimal}, Received: val}
+	}
+
+	var (
+		d   Decimal
+		err error
+	)
+	switch vr.Type() {
+	case bsontype.Decimal128:
+		var v primitive.Decimal128
+		v, err = vr.ReadDecimal128()
+		d = Decimal(v)
+	case bsontype.String:
+		var s string
+		if s, err = vr.ReadString(); err == nil {
+			d, err = String2Decimal(s)
+		}
+	case bsontype.Int32:
+		var i int32
+		if i, err = vr.ReadInt32(); err == nil {
+			d, err = String2Decimal(strconv.FormatInt(int64(i), 10))
+		}
+	case bsontype.Int64:
+		var i int64
+		if i, err = vr.ReadInt64(); err == nil {
+			d, err = String2Decimal(strconv.FormatInt(i, 10))
+		}
+	case bsontype.Double:
+		var f float64
+		if f, err = vr.ReadDouble(); err == nil {
+			d, err = String2Decimal(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case bsontype.Null:
+		err = vr.ReadNull()
+	case bsontype.Undefined:
+		err = vr.ReadUndefined()
+	default:
+		return fmt.Errorf("cannot decode %v into a Decimal", vr.Type())
+	}
+	if err != nil {
+		return err
+	}
+	val.Set(reflect.ValueOf(d))
+	return nil
+}
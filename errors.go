@@ -0,0 +1,286 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrNotFound 文档不存在，同时满足 errors.Is(err, mongo.ErrNoDocuments)
+	ErrNotFound = errors.New("[mongo]: document not found")
+	// ErrInvalidQuery 查询条件或参数错误
+	ErrInvalidQuery = errors.New("[mongo]: invalid query")
+	// ErrTimeout 操作超时：ctx 超时、maxTimeMS、网络超时、写关注超时
+	ErrTimeout = errors.New("[mongo]: operation timeout")
+	// ErrWriteConflict 写冲突，通常出现在事务中，可以重试
+	ErrWriteConflict = errors.New("[mongo]: write conflict")
+)
+
+// 服务端错误码
+const (
+	codeBadValue             = 2
+	codeFailedToParse        = 9
+	codeTypeMismatch         = 14
+	codeMaxTimeMSExpired     = 50
+	codeWriteConcernFailed   = 64
+	codeWriteConflict        = 112
+	codeDuplicateKey         = 11000
+	codeDuplicateKeyLegacy   = 11001
+	codeDuplicateKeyUpdate   = 12582
+	labelRetryableWrite      = "RetryableWriteError"
+	labelTransientTx         = "TransientTransactionError"
+	labelNetwork             = "NetworkError"
+	labelUnknownCommitResult = "UnknownTransactionCommitResult"
+)
+
+// retryableCodes 可重试的服务端错误码：主节点切换、节点关闭、网络异常等
+var retryableCodes = map[int]bool{
+	6:                 true, // HostUnreachable
+	7:                 true, // HostNotFound
+	89:                true, // NetworkTimeout
+	91:                true, // ShutdownInProgress
+	189:               true, // PrimarySteppedDown
+	262:               true, // ExceededTimeLimit
+	9001:              true, // SocketException
+	10107:             true, // NotWritablePrimary
+	11600:             true, // InterruptedAtShutdown
+	11602:             true, // InterruptedDueToReplStateChange
+	13435:             true, // NotPrimaryNoSecondaryOk
+	13436:             true, // NotPrimaryOrSecondary
+	codeWriteConflict: true,
+}
+
+// ErrDuplicateKey 唯一索引冲突
+type ErrDuplicateKey struct {
+	// Index 冲突的索引名称
+	Index string
+	// Key 冲突的键值，服务端未返回时为 nil
+	Key map[string]interface{}
+	Err error
+}
+
+func (e *ErrDuplicateKey) Error() string {
+	return fmt.Sprintf("[mongo]: duplicate key, index[%s]: %v", e.Index, e.Err)
+}
+
+func (e *ErrDuplicateKey) Unwrap() error {
+	return e.Err
+}
+
+// BulkFailure 批量写入中单个操作的错误
+type BulkFailure struct {
+	// Index 操作在 models、docs 中的下标
+	Index   int
+	Code    int
+	Message string
+	// Err 分类后的错误，如：*ErrDuplicateKey
+	Err error
+	// Model BulkWrite 中失败的原始操作，其他批量写入为 nil
+	Model mongo.WriteModel
+}
+
+// ErrBulkPartial 批量写入部分失败，Failures 之外的操作已执行（ordered 时第一个失败之后的操作不会执行）
+type ErrBulkPartial struct {
+	Failures []BulkFailure
+	Err      error
+}
+
+func (e *ErrBulkPartial) Error() string {
+	return fmt.Sprintf("[mongo]: bulk write partial failure, %d failed: %v", len(e.Failures), e.Err)
+}
+
+func (e *ErrBulkPartial) Unwrap() error {
+	return e.Err
+}
+
+// kindError 将driver错误归类为 ErrNotFound、ErrTimeout 等，errors.Is 同时匹配分类与原错误
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return fmt.Sprintf("%v: %v", e.kind, e.err)
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// wrapError 对driver错误进行分类，已分类或无法分类的错误原样返回
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var (
+		kind *kindError
+		dup  *ErrDuplicateKey
+		bulk *ErrBulkPartial
+	)
+	if errors.As(err, &kind) || errors.As(err, &dup) || errors.As(err, &bulk) {
+		return err
+	}
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &kindError{kind: ErrNotFound, err: err}
+	}
+
+	var bwe mongo.BulkWriteException
+	if errors.As(err, &bwe) && len(bwe.WriteErrors) > 0 {
+		failures := make([]BulkFailure, 0, len(bwe.WriteErrors))
+		for _, we := range bwe.WriteErrors {
+			failures = append(failures, BulkFailure{
+				Index:   we.Index,
+				Code:    we.Code,
+				Message: we.Message,
+				Err:     wrapWriteError(we.WriteError),
+			})
+		}
+		return &ErrBulkPartial{Failures: failures, Err: err}
+	}
+
+	var we mongo.WriteException
+	if errors.As(err, &we) && len(we.WriteErrors) == 1 {
+		if wrapped, ok := classifyWriteError(we.WriteErrors[0]); ok {
+			return wrapSame(wrapped, err)
+		}
+	}
+
+	if mongo.IsDuplicateKeyError(err) {
+		return &ErrDuplicateKey{Err: err}
+	}
+	if mongo.IsTimeout(err) || hasCode(err, codeMaxTimeMSExpired) || hasCode(err, codeWriteConcernFailed) {
+		return &kindError{kind: ErrTimeout, err: err}
+	}
+	if hasCode(err, codeWriteConflict) {
+		return &kindError{kind: ErrWriteConflict, err: err}
+	}
+	if hasCode(err, codeBadValue) || hasCode(err, codeFailedToParse) || hasCode(err, codeTypeMismatch) {
+		return &kindError{kind: ErrInvalidQuery, err: err}
+	}
+	return err
+}
+
+// wrapSame 使用 wrapped 的分类包装 err，保留完整的原错误
+func wrapSame(wrapped, err error) error {
+	switch e := wrapped.(type) {
+	case *ErrDuplicateKey:
+		return &ErrDuplicateKey{Index: e.Index, Key: e.Key, Err: err}
+	case *kindError:
+		return &kindError{kind: e.kind, err: err}
+	}
+	return err
+}
+
+var dupIndexRe = regexp.MustCompile(`index: (\S+) dup key`)
+
+// wrapWriteError 单个写入错误的分类，无法分类时返回原错误
+func wrapWriteError(we mongo.WriteError) error {
+	if wrapped, ok := classifyWriteError(we); ok {
+		return wrapped
+	}
+	return we
+}
+
+func classifyWriteError(we mongo.WriteError) (error, bool) {
+	switch we.Code {
+	case codeDuplicateKey, codeDuplicateKeyLegacy, codeDuplicateKeyUpdate:
+		dup := &ErrDuplicateKey{Err: we}
+		if m := dupIndexRe.FindStringSubmatch(we.Message); len(m) == 2 {
+			dup.Index = m[1]
+		}
+		// 4.2 之后的服务端在写入错误中返回 keyValue
+		if len(we.Raw) > 0 {
+			if v, err := we.Raw.LookupErr("keyValue"); err == nil {
+				key := map[string]interface{}{}
+				if bson.Unmarshal(v.Value, &key) == nil {
+					dup.Key = key
+				}
+			}
+		}
+		return dup, true
+	case codeWriteConflict:
+		return &kindError{kind: ErrWriteConflict, err: we}, true
+	case codeMaxTimeMSExpired:
+		return &kindError{kind: ErrTimeout, err: we}, true
+	case codeBadValue, codeFailedToParse, codeTypeMismatch:
+		return &kindError{kind: ErrInvalidQuery, err: we}, true
+	}
+	return nil, false
+}
+
+// hasCode 错误链中是否存在指定的服务端错误码
+func hasCode(err error, code int) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && se.HasErrorCode(code)
+}
+
+// hasLabel 错误链中是否存在指定的错误标签
+func hasLabel(err error, label string) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && se.HasErrorLabel(label)
+}
+
+// IsDuplicateKey 是否唯一索引冲突，批量写入中任一操作冲突也返回 true
+func IsDuplicateKey(err error) bool {
+	var dup *ErrDuplicateKey
+	if errors.As(err, &dup) {
+		return true
+	}
+	var bulk *ErrBulkPartial
+	if errors.As(err, &bulk) {
+		for _, f := range bulk.Failures {
+			if IsDuplicateKey(f.Err) {
+				return true
+			}
+		}
+		return false
+	}
+	return err != nil && mongo.IsDuplicateKeyError(err)
+}
+
+// IsNetwork 是否网络错误
+func IsNetwork(err error) bool {
+	if err == nil {
+		return false
+	}
+	if mongo.IsNetworkError(err) || hasLabel(err, labelNetwork) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// IsRetryable 是否可以重试：网络错误、写冲突、主节点切换，以及带有可重试标签的错误
+// ctx 超时、取消的错误不可重试
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, ErrClientClosed) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrWriteConflict) || IsNetwork(err) {
+		return true
+	}
+	if hasLabel(err, labelRetryableWrite) || hasLabel(err, labelTransientTx) || hasLabel(err, labelUnknownCommitResult) {
+		return true
+	}
+	for code := range retryableCodes {
+		if hasCode(err, code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestWrapError(t *testing.T) {
+	raw, _ := bson.Marshal(bson.M{"code": 11000, "keyValue": bson.M{"name": "test"}})
+	dupWE := mongo.WriteError{
+		Code:    11000,
+		Message: `E11000 duplicate key error collection: db.user index: name_1 dup key: { name: "test" }`,
+		Raw:     raw,
+	}
+
+	err := wrapError(mongo.WriteException{WriteErrors: mongo.WriteErrors{dupWE}})
+	var dup *ErrDuplicateKey
+	if !errors.As(err, &dup) || dup.Index != "name_1" || dup.Key["name"] != "test" || !IsDuplicateKey(err) {
+		t.Fatalf("duplicate key error: %v", err)
+	}
+	if IsRetryable(err) {
+		t.Error("duplicate key should not be retryable")
+	}
+
+	err = wrapError(mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+		{WriteError: mongo.WriteError{Index: 1, Code: 112, Message: "WriteConflict"}},
+		{WriteError: dupWE},
+	}})
+	var bulk *ErrBulkPartial
+	if !errors.As(err, &bulk) || len(bulk.Failures) != 2 || bulk.Failures[0].Index != 1 {
+		t.Fatalf("bulk error: %v", err)
+	}
+	if !errors.Is(bulk.Failures[0].Err, ErrWriteConflict) || !IsDuplicateKey(err) {
+		t.Errorf("bulk failures: %v", bulk.Failures)
+	}
+
+	err = wrapError(mongo.ErrNoDocuments)
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("not found: %v", err)
+	}
+	if err2 := wrapError(err); err2 != err {
+		t.Error("wrapped error should not be wrapped again")
+	}
+
+	err = wrapError(fmt.Errorf("find: %w", context.DeadlineExceeded))
+	if !errors.Is(err, ErrTimeout) || IsRetryable(err) {
+		t.Errorf("timeout: %v", err)
+	}
+
+	err = wrapError(mongo.CommandError{Code: 112, Name: "WriteConflict", Labels: []string{"TransientTransactionError"}})
+	if !errors.Is(err, ErrWriteConflict) || !IsRetryable(err) {
+		t.Errorf("write conflict: %v", err)
+	}
+
+	err = wrapError(mongo.CommandError{Code: 2, Name: "BadValue"})
+	if !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("invalid query: %v", err)
+	}
+
+	err = wrapError(mongo.CommandError{Code: 9001, Labels: []string{"NetworkError"}})
+	if !IsNetwork(err) || !IsRetryable(err) {
+		t.Errorf("network: %v", err)
+	}
+
+	if IsRetryable(ErrClientClosed) || IsNetwork(nil) || IsDuplicateKey(nil) {
+		t.Error("nil or closed error classification")
+	}
+}
+
+func TestDecodeSingle(t *testing.T) {
+	missing := func() *mongo.SingleResult {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+
+	var doc map[string]interface{}
+	if err := decodeSingle(missing(), &doc, false); err != nil || doc != nil {
+		t.Fatalf("missing without strict mode: %v, %v", err, doc)
+	}
+	if err := decodeSingle(missing(), &doc, true); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing with strict mode: %v", err)
+	}
+	if err := decodeSingle(missing(), nil, true); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing without doc: %v", err)
+	}
+
+	found := mongo.NewSingleResultFromDocument(bson.D{{Key: "name", Value: "test"}}, nil, nil)
+	if err := decodeSingle(found, &doc, true); err != nil || doc["name"] != "test" {
+		t.Fatalf("found: %v, %v", err, doc)
+	}
+
+	var ref Foreign[map[string]interface{}]
+	if _, err := ref.MustGetData(context.Background(), nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("empty foreign key: %v", err)
+	}
+}
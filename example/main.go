@@ -169,7 +169,10 @@ func main() {
 	// 事务，要求mongo版本>4.0，需要mongo副本集群
 	// 返回nil事务执行成功
 	err = mongo.TransSession(client, func(sessionCtx mongo.SessionContext) error {
-		sessTb1 := mongo.NewORMByClient(sessionCtx, client, "example", "table1", mongoRef)
+		// 方式1：使用事务上下文创建ORM
+		// sessTb1 := mongo.NewORMByClient(sessionCtx, client, "example", "table1", mongoRef)
+		// 方式2：将已有ORM绑定到事务
+		sessTb1 := tb1.WithSession(sessionCtx)
 		// 操作
 		//sessTb1.InsertOne()
 		//sessTb1.DeleteOne()
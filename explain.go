@@ -0,0 +1,314 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// explain 详细程度
+const (
+	// ExplainQueryPlanner 只返回选中的执行计划，不执行查询
+	ExplainQueryPlanner = "queryPlanner"
+	// ExplainExecutionStats 执行查询并返回执行统计
+	ExplainExecutionStats = "executionStats"
+	// ExplainAllPlansExecution 执行查询并返回所有候选计划的执行统计
+	ExplainAllPlansExecution = "allPlansExecution"
+)
+
+// ExplainPlan 执行计划摘要
+type ExplainPlan struct {
+	Namespace string
+	// Stages 选中计划的阶段，从根节点到叶子节点，如：LIMIT、FETCH、IXSCAN
+	Stages []string
+	// Indexes 使用的索引名称
+	Indexes []string
+	// CollScan 是否存在全表扫描
+	CollScan bool
+
+	// 以下统计需要 ExplainExecutionStats 或 ExplainAllPlansExecution
+	DocsExamined  int64
+	KeysExamined  int64
+	Returned      int64
+	ExecutionTime time.Duration
+
+	// Raw explain 原始结果
+	Raw bson.M
+}
+
+// SetCollScanWarning 开启后，ORM 列表查询为慢查询（见 SetSlowQuery）时执行 explain（queryPlanner，不执行查询），
+// 出现全表扫描时输出警告日志，未设置慢查询阈值时不检查，正常的查询不会增加请求
+func (c *Client) SetCollScanWarning(b bool) *Client {
+	c.collScanWarning = b
+	return c
+}
+
+// ExplainFind 查询的执行计划
+func (c *Collection) ExplainFind(ctx context.Context, filter *Query, opts *FindOptions, verbosity string) (*ExplainPlan, error) {
+	ctxObj := c.ctx
+	if ctx != nil {
+		ctxObj = ctx
+	}
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := bson.D{{Key: "find", Value: c.collectionName}, {Key: "filter", Value: cond}}
+	var readOpts *BasicReadOptions
+	if opts != nil {
+		readOpts = &opts.BasicReadOptions
+		if len(opts.projection) > 0 {
+			cmd = append(cmd, bson.E{Key: "projection", Value: opts.projection})
+		} else if opts.field != nil {
+			cmd = append(cmd, bson.E{Key: "projection", Value: opts.field})
+		}
+		if opts.sort != nil {
+			cmd = append(cmd, bson.E{Key: "sort", Value: opts.sort})
+		}
+		if opts.skip != nil {
+			cmd = append(cmd, bson.E{Key: "skip", Value: *opts.skip})
+		}
+		if opts.limit != nil {
+			cmd = append(cmd, bson.E{Key: "limit", Value: *opts.limit})
+		}
+		cmd = opts.BasicQueryOptions.appendCmd(cmd, true)
+	}
+	return c.explain(ctxObj, cmd, readOpts, verbosity)
+}
+
+// ExplainCount 计数的执行计划
+func (c *Collection) ExplainCount(ctx context.Context, filter *Query, opts *CountOptions, verbosity string) (*ExplainPlan, error) {
+	ctxObj := c.ctx
+	if ctx != nil {
+		ctxObj = ctx
+	}
+	cond, err := c.cond(ctxObj, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := bson.D{{Key: "count", Value: c.collectionName}, {Key: "query", Value: cond}}
+	var readOpts *BasicReadOptions
+	if opts != nil {
+		readOpts = &opts.BasicReadOptions
+		if opts.skip != nil {
+			cmd = append(cmd, bson.E{Key: "skip", Value: *opts.skip})
+		}
+		if opts.limit != nil {
+			cmd = append(cmd, bson.E{Key: "limit", Value: *opts.limit})
+		}
+		cmd = opts.BasicQueryOptions.appendCmd(cmd, false)
+	}
+	return c.explain(ctxObj, cmd, readOpts, verbosity)
+}
+
+// ExplainAggregate 聚合的执行计划
+func (c *Collection) ExplainAggregate(ctx context.Context, opts *AggregateOptions, verbosity string, pipeline ...interface{}) (*ExplainPlan, error) {
+	ctxObj := c.ctx
+	if ctx != nil {
+		ctxObj = ctx
+	}
+	pipeline, err := c.tenantPipeline(ctxObj, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if pipeline == nil {
+		pipeline = []interface{}{}
+	}
+
+	cmd := bson.D{{Key: "aggregate", Value: c.collectionName}, {Key: "pipeline", Value: pipeline}, {Key: "cursor", Value: bson.D{}}}
+	var readOpts *BasicReadOptions
+	if opts != nil {
+		readOpts = &opts.BasicReadOptions
+		if opts.allowDiskUse != nil {
+			cmd = append(cmd, bson.E{Key: "allowDiskUse", Value: *opts.allowDiskUse})
+		}
+		cmd = opts.BasicQueryOptions.appendCmd(cmd, true)
+	}
+	return c.explain(ctxObj, cmd, readOpts, verbosity)
+}
+
+func (c *Collection) explain(ctx context.Context, cmd bson.D, readOpts *BasicReadOptions, verbosity string) (*ExplainPlan, error) {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err = concernErr(readOpts, nil); err != nil {
+		return nil, err
+	}
+	if verbosity == "" {
+		verbosity = ExplainQueryPlanner
+	}
+
+	runOpts := options.RunCmd()
+	if readOpts != nil && readOpts.readPref != nil {
+		runOpts.SetReadPreference(readOpts.readPref)
+	}
+
+	var raw bson.M
+	err = c.db.RunCommand(ctx, bson.D{{Key: "explain", Value: cmd}, {Key: "verbosity", Value: verbosity}}, runOpts).Decode(&raw)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return parseExplain(raw), nil
+}
+
+// appendCmd 添加命令选项，count 命令不支持 comment
+func (op *BasicQueryOptions) appendCmd(cmd bson.D, withComment bool) bson.D {
+	if op.maxTime != nil {
+		cmd = append(cmd, bson.E{Key: "maxTimeMS", Value: op.maxTime.Milliseconds()})
+	}
+	if op.hint != nil {
+		cmd = append(cmd, bson.E{Key: "hint", Value: op.hint})
+	}
+	if op.collation != nil {
+		cmd = append(cmd, bson.E{Key: "collation", Value: op.collation.ToDocument()})
+	}
+	if withComment && op.comment != nil {
+		cmd = append(cmd, bson.E{Key: "comment", Value: *op.comment})
+	}
+	return cmd
+}
+
+// parseExplain 解析 explain 结果，兼容分片集群、聚合及 SBE 引擎
+func parseExplain(raw bson.M) *ExplainPlan {
+	plan := &ExplainPlan{Raw: raw}
+	explainDoc(raw, plan)
+	return plan
+}
+
+func explainDoc(doc primitive.M, plan *ExplainPlan) {
+	if qp, ok := doc["queryPlanner"].(primitive.M); ok {
+		if ns, ok := qp["namespace"].(string); ok && plan.Namespace == "" {
+			plan.Namespace = ns
+		}
+		if wp, ok := qp["winningPlan"].(primitive.M); ok {
+			explainStage(wp, plan)
+		}
+	}
+
+	if stats, ok := doc["executionStats"].(primitive.M); ok {
+		plan.Returned += explainInt(stats["nReturned"])
+		plan.DocsExamined += explainInt(stats["totalDocsExamined"])
+		plan.KeysExamined += explainInt(stats["totalKeysExamined"])
+		plan.ExecutionTime += time.Duration(explainInt(stats["executionTimeMillis"])) * time.Millisecond
+	}
+
+	// 聚合：{"stages": [{"$cursor": {...}}, ...]}，分片聚合：{"shards": {"shard1": {...}}}
+	if stages, ok := doc["stages"].(primitive.A); ok {
+		for _, s := range stages {
+			if stage, ok := s.(primitive.M); ok {
+				if cursor, ok := stage["$cursor"].(primitive.M); ok {
+					explainDoc(cursor, plan)
+				}
+			}
+		}
+	}
+	if shards, ok := doc["shards"].(primitive.M); ok {
+		for _, s := range shards {
+			if shard, ok := s.(primitive.M); ok {
+				explainDoc(shard, plan)
+			}
+		}
+	}
+}
+
+func explainStage(stage primitive.M, plan *ExplainPlan) {
+	// SBE 引擎：{"queryPlan": {...}, "slotBasedPlan": {...}}
+	if qp, ok := stage["queryPlan"].(primitive.M); ok {
+		explainStage(qp, plan)
+		return
+	}
+
+	if name, ok := stage["stage"].(string); ok {
+		plan.Stages = append(plan.Stages, name)
+		if name == "COLLSCAN" {
+			plan.CollScan = true
+		}
+	}
+	if indexName, ok := stage["indexName"].(string); ok {
+		plan.Indexes = append(plan.Indexes, indexName)
+	}
+
+	if input, ok := stage["inputStage"].(primitive.M); ok {
+		explainStage(input, plan)
+	}
+	for _, key := range []string{"inputStages", "shards"} {
+		if inputs, ok := stage[key].(primitive.A); ok {
+			for _, s := range inputs {
+				input, ok := s.(primitive.M)
+				if !ok {
+					continue
+				}
+				// 分片：{"shardName": "", "winningPlan": {...}}
+				if wp, ok := input["winningPlan"].(primitive.M); ok {
+					explainStage(wp, plan)
+				} else {
+					explainStage(input, plan)
+				}
+			}
+		}
+	}
+}
+
+func explainInt(v interface{}) int64 {
+	switch v := v.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// Explain 查询（ToData 列表查询）的执行计划，verbosity 为空时使用 ExplainQueryPlanner
+func (orm *ORM) Explain(verbosity string) (*ExplainPlan, error) {
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
+
+	o := orm.scoped()
+	return table.ExplainFind(orm.ctx, o.cond(), o.findOptions(), verbosity)
+}
+
+// findOptions 列表查询选项
+func (orm *ORM) findOptions() *FindOptions {
+	opts := NewFindOptions()
+	opts.BasicQueryOptions = orm.Q.opts
+	opts.Select(orm.Q.Select)
+	if len(orm.Q.Limit) == 1 {
+		opts.Limit(int64(orm.Q.Limit[0]))
+	} else if len(orm.Q.Limit) == 2 {
+		opts.Skip(int64(orm.Q.Limit[0]))
+		opts.Limit(int64(orm.Q.Limit[1]))
+	}
+	opts.Projection(orm.Q.Projection)
+	opts.Sort(orm.Q.Order)
+	return opts
+}
+
+// warnCollScan 开启 SetCollScanWarning 时检查已执行的慢查询是否为全表扫描
+func (orm *ORM) warnCollScan(ctx context.Context, table *Collection, q *Query, opts *FindOptions, elapsed time.Duration) {
+	// 事务中不支持 explain
+	if !table.collScanWarning || table.slowQuery <= 0 || elapsed < table.slowQuery ||
+		mongo.SessionFromContext(ctx) != nil {
+		return
+	}
+
+	plan, err := table.ExplainFind(ctx, q, opts, ExplainQueryPlanner)
+	if err != nil || !plan.CollScan {
+		return
+	}
+	table.Logger().Warn("mongo query is a collection scan", "table", orm.tableName, "duration", elapsed, "query", q.JSON())
+}
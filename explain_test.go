@@ -0,0 +1,45 @@
+package mongo
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseExplain(t *testing.T) {
+	raw := bson.M{
+		"queryPlanner": bson.M{
+			"namespace": "test.user",
+			"winningPlan": bson.M{
+				"stage": "LIMIT",
+				"inputStage": bson.M{
+					"stage":      "FETCH",
+					"inputStage": bson.M{"stage": "IXSCAN", "indexName": "name_1"},
+				},
+			},
+		},
+		"executionStats": bson.M{"nReturned": int32(10), "totalDocsExamined": int32(10), "totalKeysExamined": int64(12), "executionTimeMillis": int32(3)},
+	}
+	plan := parseExplain(raw)
+	if plan.Namespace != "test.user" || len(plan.Stages) != 3 || plan.Stages[2] != "IXSCAN" || plan.CollScan {
+		t.Fatalf("plan error: %+v", plan)
+	}
+	if len(plan.Indexes) != 1 || plan.Indexes[0] != "name_1" || plan.KeysExamined != 12 || plan.Returned != 10 {
+		t.Fatalf("plan error: %+v", plan)
+	}
+
+	sharded := bson.M{
+		"queryPlanner": bson.M{
+			"winningPlan": bson.M{
+				"stage": "SHARD_MERGE",
+				"shards": bson.A{
+					bson.M{"shardName": "s1", "winningPlan": bson.M{"queryPlan": bson.M{"stage": "COLLSCAN"}}},
+				},
+			},
+		},
+	}
+	plan = parseExplain(sharded)
+	if !plan.CollScan || len(plan.Stages) != 2 {
+		t.Fatalf("sharded plan error: %+v", plan)
+	}
+}
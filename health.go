@@ -0,0 +1,193 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Health client 健康状态
+type Health struct {
+	// OK ping 成功
+	OK bool
+	// Err ping 的错误
+	Err error
+	// Latency ping 耗时
+	Latency time.Duration
+	// Topology 集群类型，如：Single、ReplicaSetWithPrimary、Sharded
+	Topology string
+	// SetName 副本集名称
+	SetName string
+	Servers []ServerHealth
+	Pool    PoolStats
+	// InFlight 执行中的操作数量
+	InFlight int64
+	// Sessions 执行中的session数量
+	Sessions int
+	Closed   bool
+}
+
+// ServerHealth 节点状态
+type ServerHealth struct {
+	Addr string
+	// Kind 节点类型，如：RSPrimary、RSSecondary、Standalone、Mongos
+	Kind      string
+	RTT       time.Duration
+	LastError error
+}
+
+// PoolStats 连接池状态
+type PoolStats struct {
+	// Open 已建立的连接数
+	Open int64
+	// CheckedOut 使用中的连接数
+	CheckedOut int64
+	// CheckOutFailed 获取连接失败次数
+	CheckOutFailed int64
+	// Cleared 连接池被清空的次数
+	Cleared int64
+}
+
+// clientMonitor 记录集群拓扑和连接池状态
+type clientMonitor struct {
+	mu       sync.RWMutex
+	topology description.Topology
+
+	open           int64
+	checkedOut     int64
+	checkOutFailed int64
+	cleared        int64
+
+	userServer  *event.ServerMonitor
+	userPool    *event.PoolMonitor
+	userCommand *event.CommandMonitor
+
+	// 命令、连接池监控，started 记录执行中的命令
+	hookMu       sync.RWMutex
+	commandHooks []CommandHook
+	poolHooks    []PoolHook
+	started      sync.Map
+
+	// 连接获取指标，checkouts 记录每个地址开始获取连接的时间
+	metrics    Metrics
+	checkoutMu sync.Mutex
+	checkouts  map[string][]time.Time
+}
+
+// newClientMonitor 创建监控，opt 中已有的监控会继续被调用
+func newClientMonitor(opt *options.ClientOptions) *clientMonitor {
+	m := new(clientMonitor)
+	if opt != nil {
+		m.userServer = opt.ServerMonitor
+		m.userPool = opt.PoolMonitor
+		m.userCommand = opt.Monitor
+	}
+	return m
+}
+
+func (m *clientMonitor) options() *options.ClientOptions {
+	serverMonitor := new(event.ServerMonitor)
+	if m.userServer != nil {
+		*serverMonitor = *m.userServer
+	}
+	userTopology := serverMonitor.TopologyDescriptionChanged
+	serverMonitor.TopologyDescriptionChanged = func(e *event.TopologyDescriptionChangedEvent) {
+		m.mu.Lock()
+		m.topology = e.NewDescription
+		m.mu.Unlock()
+		if userTopology != nil {
+			userTopology(e)
+		}
+	}
+
+	poolMonitor := &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			m.poolEvent(e)
+			if m.userPool != nil && m.userPool.Event != nil {
+				m.userPool.Event(e)
+			}
+			m.dispatchPool(e)
+			m.checkout(e)
+		},
+	}
+
+	return options.Client().SetServerMonitor(serverMonitor).SetPoolMonitor(poolMonitor).SetMonitor(m.commandMonitor())
+}
+
+func (m *clientMonitor) poolEvent(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		atomic.AddInt64(&m.open, 1)
+	case event.ConnectionClosed:
+		atomic.AddInt64(&m.open, -1)
+	case event.GetSucceeded:
+		atomic.AddInt64(&m.checkedOut, 1)
+	case event.ConnectionReturned:
+		atomic.AddInt64(&m.checkedOut, -1)
+	case event.GetFailed:
+		atomic.AddInt64(&m.checkOutFailed, 1)
+	case event.PoolCleared:
+		atomic.AddInt64(&m.cleared, 1)
+	}
+}
+
+func (m *clientMonitor) poolStats() PoolStats {
+	return PoolStats{
+		Open:           atomic.LoadInt64(&m.open),
+		CheckedOut:     atomic.LoadInt64(&m.checkedOut),
+		CheckOutFailed: atomic.LoadInt64(&m.checkOutFailed),
+		Cleared:        atomic.LoadInt64(&m.cleared),
+	}
+}
+
+func (m *clientMonitor) topologyDesc() description.Topology {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.topology
+}
+
+// Health 获取client健康状态，包括 ping 耗时、集群拓扑、连接池状态
+// ping 失败不返回错误，记录在 Health.Err 中；client 已关闭时返回 ErrClientClosed
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	h := new(Health)
+	c.lifeMu.Lock()
+	h.Closed = c.closed
+	h.InFlight = c.inFlight
+	c.lifeMu.Unlock()
+	if h.Closed {
+		return h, ErrClientClosed
+	}
+
+	ctxObj := c.ctx
+	if ctx != nil {
+		ctxObj = ctx
+	}
+	start := time.Now()
+	h.Err = c.mongoClient.Ping(ctxObj, readpref.Primary())
+	h.Latency = time.Since(start)
+	h.OK = h.Err == nil
+	h.Sessions = c.mongoClient.NumberSessionsInProgress()
+
+	if c.monitor != nil {
+		topology := c.monitor.topologyDesc()
+		h.Topology = topology.Kind.String()
+		h.SetName = topology.SetName
+		for _, server := range topology.Servers {
+			h.Servers = append(h.Servers, ServerHealth{
+				Addr:      server.Addr.String(),
+				Kind:      server.Kind.String(),
+				RTT:       server.AverageRTT,
+				LastError: server.LastError,
+			})
+		}
+		h.Pool = c.monitor.poolStats()
+	}
+	return h, nil
+}
@@ -0,0 +1,46 @@
+// Package mongoconf 从 json、yaml、toml 文件加载 mongo.Conf
+//
+//	conf, err := mongoconf.FromFile("conf/mongo.yaml")
+//	client, err := mongo.NewClientByConf(ctx, "app", conf)
+package mongoconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/assembly-hub/mongo"
+)
+
+// FromFile 从文件加载配置，根据后缀名解析：.json、.yaml、.yml、.toml
+func FromFile(path string) (*mongo.Conf, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := new(mongo.Conf)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(bs, conf)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(bs, conf)
+	case ".toml":
+		err = toml.Unmarshal(bs, conf)
+	default:
+		return nil, fmt.Errorf("mongo conf: file[%s] type not supported", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mongo conf: file[%s] parse error: %w", path, err)
+	}
+
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
@@ -0,0 +1,37 @@
+package mongoconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFromFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"mongo.yaml": "hosts: [\"127.0.0.1:27017\"]\ndb: test\nmax_pool_size: 20\noptions:\n  appName: svc\n",
+		"mongo.toml": "hosts = [\"127.0.0.1:27017\"]\ndb = \"test\"\nmax_pool_size = 20\n[options]\nappName = \"svc\"\n",
+		"mongo.json": `{"hosts": ["127.0.0.1:27017"], "db": "test", "max_pool_size": 20, "options": {"appName": "svc"}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		conf, err := FromFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if conf.DB != "test" || conf.MaxPoolSize != 20 || conf.Options["appName"] != "svc" {
+			t.Fatalf("%s: conf error: %s", name, conf)
+		}
+	}
+
+	path := filepath.Join(dir, "mongo.ini")
+	if err := os.WriteFile(path, []byte("db=test"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromFile(path); err == nil {
+		t.Fatal("unsupported file type must be error")
+	}
+}
@@ -0,0 +1,30 @@
+module github.com/assembly-hub/mongo/mongoconf
+
+go 1.19
+
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/assembly-hub/mongo v0.0.0-20261019093610-4b9ecaa58310
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/assembly-hub/basics v1.3.1 // indirect
+	github.com/assembly-hub/log v1.2.0 // indirect
+	github.com/assembly-hub/task v1.2.0 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/go-redis/redis/v8 v8.11.5 // indirect
+	github.com/go-stack/stack v1.8.0 // indirect
+	github.com/golang/snappy v0.0.1 // indirect
+	github.com/klauspost/compress v1.13.6 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/xdg-go/pbkdf2 v1.0.0 // indirect
+	github.com/xdg-go/scram v1.1.1 // indirect
+	github.com/xdg-go/stringprep v1.0.3 // indirect
+	github.com/youmark/pkcs8 v0.0.0-20181117223130-1be2e3e5546d // indirect
+	go.mongodb.org/mongo-driver v1.9.4 // indirect
+	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d // indirect
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
+	golang.org/x/text v0.3.7 // indirect
+)
@@ -0,0 +1,58 @@
+// Package mongootel mongo.Tracer 的 OpenTelemetry 实现
+//
+//	client.SetTracer(mongootel.New(nil))
+package mongootel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/assembly-hub/mongo"
+)
+
+const instrumentationName = "github.com/assembly-hub/mongo"
+
+// Tracer OpenTelemetry 追踪
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ mongo.Tracer = (*Tracer)(nil)
+
+// New 创建追踪，tp 为 nil 时使用全局 otel.GetTracerProvider()
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// StartSpan 实现 mongo.Tracer，span 名称：集合.方法，如：user.FindDocs
+func (t *Tracer) StartSpan(ctx context.Context, op *mongo.Operation) (context.Context, mongo.Span) {
+	ctx, span := t.tracer.Start(ctx, op.Collection+"."+op.Name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "mongodb"),
+			attribute.String("db.name", op.Database),
+			attribute.String("db.mongodb.collection", op.Collection),
+			attribute.String("db.operation", op.Name),
+			attribute.String("db.mongodb.kind", op.Kind),
+		))
+	return ctx, otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,46 @@
+package mongootel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/assembly-hub/mongo"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	op := &mongo.Operation{Kind: mongo.OperationCollection, Database: "db", Collection: "user", Name: "FindDocs"}
+	ctx, span := tracer.StartSpan(context.Background(), op)
+	_, child := tracer.StartSpan(ctx, op)
+	child.End(errors.New("failed"))
+	span.End(nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(spans))
+	}
+	if spans[0].Name() != "user.FindDocs" {
+		t.Errorf("name = %s", spans[0].Name())
+	}
+	if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Error("child span parent mismatch")
+	}
+	if spans[0].Status().Code != codes.Error || spans[1].Status().Code != codes.Unset {
+		t.Errorf("status = %v, %v", spans[0].Status().Code, spans[1].Status().Code)
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range spans[1].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsString()
+	}
+	if attrs["db.system"] != "mongodb" || attrs["db.name"] != "db" || attrs["db.mongodb.collection"] != "user" {
+		t.Errorf("attributes = %v", attrs)
+	}
+}
@@ -0,0 +1,111 @@
+// Package mongoprom mongo.Metrics 的 Prometheus 实现
+//
+//	m, err := mongoprom.New(prometheus.DefaultRegisterer, "app")
+//	client.SetMetrics(m)
+package mongoprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/assembly-hub/mongo"
+)
+
+var operationLabels = []string{"kind", "database", "collection", "operation"}
+
+// Metrics Prometheus 指标
+//
+//	{namespace}_mongo_operation_duration_seconds：操作耗时
+//	{namespace}_mongo_operation_errors_total：操作错误数
+//	{namespace}_mongo_operation_retries_total：操作重试次数
+//	{namespace}_mongo_pool_checkout_duration_seconds：获取连接等待时间
+//	{namespace}_mongo_pool_checkout_errors_total：获取连接失败数
+type Metrics struct {
+	duration       *prometheus.HistogramVec
+	errors         *prometheus.CounterVec
+	retries        *prometheus.CounterVec
+	checkout       *prometheus.HistogramVec
+	checkoutErrors *prometheus.CounterVec
+}
+
+var (
+	_ mongo.Metrics      = (*Metrics)(nil)
+	_ mongo.RetryMetrics = (*Metrics)(nil)
+)
+
+// New 创建并注册指标，reg 为 nil 时使用 prometheus.DefaultRegisterer
+func New(reg prometheus.Registerer, namespace string) (*Metrics, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	m := &Metrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "mongo",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of mongo collection and orm operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, operationLabels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "mongo",
+			Name:      "operation_errors_total",
+			Help:      "Number of failed mongo collection and orm operations.",
+		}, operationLabels),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "mongo",
+			Name:      "operation_retries_total",
+			Help:      "Number of retried mongo collection operations.",
+		}, operationLabels),
+		checkout: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "mongo",
+			Name:      "pool_checkout_duration_seconds",
+			Help:      "Time spent waiting for a pooled connection.",
+			Buckets:   []float64{.0005, .001, .005, .01, .05, .1, .5, 1, 5},
+		}, []string{"address"}),
+		checkoutErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "mongo",
+			Name:      "pool_checkout_errors_total",
+			Help:      "Number of failed connection checkouts.",
+		}, []string{"address"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.duration, m.errors, m.retries, m.checkout, m.checkoutErrors} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// OperationDone 实现 mongo.Metrics
+func (m *Metrics) OperationDone(op *mongo.Operation, d time.Duration, err error) {
+	labels := prometheus.Labels{
+		"kind":       op.Kind,
+		"database":   op.Database,
+		"collection": op.Collection,
+		"operation":  op.Name,
+	}
+	m.duration.With(labels).Observe(d.Seconds())
+	if err != nil {
+		m.errors.With(labels).Inc()
+	}
+}
+
+// OperationRetry 实现 mongo.RetryMetrics
+func (m *Metrics) OperationRetry(op *mongo.Operation, attempt int, err error) {
+	m.retries.WithLabelValues(op.Kind, op.Database, op.Collection, op.Name).Inc()
+}
+
+// PoolCheckout 实现 mongo.Metrics
+func (m *Metrics) PoolCheckout(address string, wait time.Duration, err error) {
+	m.checkout.WithLabelValues(address).Observe(wait.Seconds())
+	if err != nil {
+		m.checkoutErrors.WithLabelValues(address).Inc()
+	}
+}
@@ -0,0 +1,56 @@
+package mongoprom
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/assembly-hub/mongo"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := New(reg, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op := &mongo.Operation{Kind: mongo.OperationORM, Database: "db", Collection: "user", Name: "ToData"}
+	m.OperationDone(op, time.Millisecond, nil)
+	m.OperationDone(op, time.Millisecond, errors.New("failed"))
+	m.OperationRetry(op, 2, errors.New("failed"))
+	m.PoolCheckout("localhost:27017", time.Millisecond, nil)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, f := range families {
+		for _, metric := range f.GetMetric() {
+			if h := metric.GetHistogram(); h != nil {
+				got[f.GetName()] = float64(h.GetSampleCount())
+			} else {
+				got[f.GetName()] = metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	want := map[string]float64{
+		"test_mongo_operation_duration_seconds":     2,
+		"test_mongo_operation_errors_total":         1,
+		"test_mongo_operation_retries_total":        1,
+		"test_mongo_pool_checkout_duration_seconds": 1,
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+
+	if _, err = New(reg, "test"); err == nil {
+		t.Error("duplicate register should fail")
+	}
+}
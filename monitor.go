@@ -0,0 +1,368 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Logger 日志接口，与 log/slog 的 *slog.Logger 方法一致，可直接传入 slog.Default()
+// args 为键值对，如：logger.Warn("mongo slow query", "table", "user", "duration", d)
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// stdLogger 默认日志，输出到标准库 log，不输出 Debug
+type stdLogger struct{}
+
+func (stdLogger) Debug(msg string, args ...interface{}) {}
+
+func (stdLogger) Info(msg string, args ...interface{}) {
+	stdLog("INFO", msg, args)
+}
+
+func (stdLogger) Warn(msg string, args ...interface{}) {
+	stdLog("WARN", msg, args)
+}
+
+func (stdLogger) Error(msg string, args ...interface{}) {
+	stdLog("ERROR", msg, args)
+}
+
+func stdLog(level, msg string, args []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("mongo: level=")
+	sb.WriteString(level)
+	sb.WriteString(" msg=")
+	sb.WriteString(fmt.Sprintf("%q", msg))
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			sb.WriteString(fmt.Sprintf(" %v=%v", args[i], args[i+1]))
+		} else {
+			sb.WriteString(fmt.Sprintf(" !BADKEY=%v", args[i]))
+		}
+	}
+	log.Print(sb.String())
+}
+
+// SetLogger 设置日志，nil 使用默认日志（标准库 log）
+func (c *Client) SetLogger(logger Logger) *Client {
+	c.logger = logger
+	return c
+}
+
+// Logger 当前使用的日志
+func (c *Client) Logger() Logger {
+	if c.logger == nil {
+		return stdLogger{}
+	}
+	return c.logger
+}
+
+// SetSlowQuery 设置慢查询阈值，ORM 操作耗时超过阈值时输出 Warn 日志，包含查询条件 Query.JSON()
+// 0 表示关闭（默认）
+func (c *Client) SetSlowQuery(threshold time.Duration) *Client {
+	c.slowQuery = threshold
+	return c
+}
+
+// CommandEvent 命令事件
+type CommandEvent struct {
+	RequestID    int64
+	ConnectionID string
+	Database     string
+	Collection   string
+	// Operation 命令名称，如：find、insert、update、aggregate
+	Operation string
+	// Filter 查询条件 JSON，值被替换为 "?"，只保留字段和操作符
+	Filter string
+	// Duration 执行耗时，CommandStarted 中为 0
+	Duration time.Duration
+	// Err 执行错误，只在 CommandFailed 中存在
+	Err error
+}
+
+// CommandHook 命令监控
+type CommandHook interface {
+	CommandStarted(ctx context.Context, e *CommandEvent)
+	CommandSucceeded(ctx context.Context, e *CommandEvent)
+	CommandFailed(ctx context.Context, e *CommandEvent)
+}
+
+// PoolEvent 连接池事件
+type PoolEvent struct {
+	// Type 事件类型，如：ConnectionCreated、ConnectionCheckedOut、ConnectionCheckOutFailed
+	Type         string
+	Address      string
+	ConnectionID uint64
+	Reason       string
+}
+
+// PoolHook 连接池监控
+type PoolHook interface {
+	PoolEvent(e *PoolEvent)
+}
+
+// AddCommandHook 添加命令监控，可在client创建后随时添加
+func (c *Client) AddCommandHook(hook CommandHook) *Client {
+	c.monitor.hookMu.Lock()
+	defer c.monitor.hookMu.Unlock()
+	c.monitor.commandHooks = append(append([]CommandHook{}, c.monitor.commandHooks...), hook)
+	return c
+}
+
+// AddPoolHook 添加连接池监控
+func (c *Client) AddPoolHook(hook PoolHook) *Client {
+	c.monitor.hookMu.Lock()
+	defer c.monitor.hookMu.Unlock()
+	c.monitor.poolHooks = append(append([]PoolHook{}, c.monitor.poolHooks...), hook)
+	return c
+}
+
+// commandHookList 当前命令监控，返回的切片不会被修改
+func (m *clientMonitor) commandHookList() []CommandHook {
+	m.hookMu.RLock()
+	defer m.hookMu.RUnlock()
+	return m.commandHooks
+}
+
+func (m *clientMonitor) poolHookList() []PoolHook {
+	m.hookMu.RLock()
+	defer m.hookMu.RUnlock()
+	return m.poolHooks
+}
+
+func (m *clientMonitor) commandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if m.userCommand != nil && m.userCommand.Started != nil {
+				m.userCommand.Started(ctx, e)
+			}
+			hooks := m.commandHookList()
+			if len(hooks) <= 0 {
+				return
+			}
+
+			evt := &CommandEvent{
+				RequestID:    e.RequestID,
+				ConnectionID: e.ConnectionID,
+				Database:     e.DatabaseName,
+				Collection:   commandCollection(e.Command, e.CommandName),
+				Operation:    e.CommandName,
+				Filter:       commandFilter(e.Command),
+			}
+			m.started.Store(e.RequestID, evt)
+			for _, hook := range hooks {
+				hook.CommandStarted(ctx, evt)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			if m.userCommand != nil && m.userCommand.Succeeded != nil {
+				m.userCommand.Succeeded(ctx, e)
+			}
+			evt, hooks := m.finished(&e.CommandFinishedEvent)
+			for _, hook := range hooks {
+				hook.CommandSucceeded(ctx, evt)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			if m.userCommand != nil && m.userCommand.Failed != nil {
+				m.userCommand.Failed(ctx, e)
+			}
+			evt, hooks := m.finished(&e.CommandFinishedEvent)
+			if evt != nil {
+				evt.Err = fmt.Errorf("%s", e.Failure)
+			}
+			for _, hook := range hooks {
+				hook.CommandFailed(ctx, evt)
+			}
+		},
+	}
+}
+
+// finished 取出 started 中记录的事件并补充耗时
+func (m *clientMonitor) finished(e *event.CommandFinishedEvent) (*CommandEvent, []CommandHook) {
+	v, ok := m.started.LoadAndDelete(e.RequestID)
+	hooks := m.commandHookList()
+	if len(hooks) <= 0 {
+		return nil, nil
+	}
+
+	evt := &CommandEvent{
+		RequestID:    e.RequestID,
+		ConnectionID: e.ConnectionID,
+		Operation:    e.CommandName,
+	}
+	if ok {
+		started := *v.(*CommandEvent)
+		evt = &started
+	}
+	evt.Duration = time.Duration(e.DurationNanos)
+	return evt, hooks
+}
+
+func (m *clientMonitor) dispatchPool(e *event.PoolEvent) {
+	hooks := m.poolHookList()
+	if len(hooks) <= 0 {
+		return
+	}
+
+	evt := &PoolEvent{
+		Type:         e.Type,
+		Address:      e.Address,
+		ConnectionID: e.ConnectionID,
+		Reason:       e.Reason,
+	}
+	for _, hook := range hooks {
+		hook.PoolEvent(evt)
+	}
+}
+
+// commandCollection 命令的集合名称，getMore 等命令在 collection 字段中
+func commandCollection(cmd bson.Raw, name string) string {
+	if v, err := cmd.LookupErr(name); err == nil {
+		if s, ok := v.StringValueOK(); ok {
+			return s
+		}
+	}
+	if v, err := cmd.LookupErr("collection"); err == nil {
+		if s, ok := v.StringValueOK(); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// commandFilter 命令中的查询条件，值被替换为 "?"
+func commandFilter(cmd bson.Raw) string {
+	var filter interface{}
+	for _, key := range []string{"filter", "query", "pipeline"} {
+		if v, err := cmd.LookupErr(key); err == nil {
+			filter = redactValue(v)
+			break
+		}
+	}
+
+	// update、delete：{"updates": [{"q": {...}, "u": {...}}]}
+	if filter == nil {
+		for _, key := range []string{"updates", "deletes"} {
+			v, err := cmd.LookupErr(key)
+			if err != nil {
+				continue
+			}
+			arr, ok := v.ArrayOK()
+			if !ok {
+				continue
+			}
+			values, _ := arr.Values()
+			var qs bson.A
+			for _, item := range values {
+				if doc, ok := item.DocumentOK(); ok {
+					if q, err := doc.LookupErr("q"); err == nil {
+						qs = append(qs, redactValue(q))
+					}
+				}
+			}
+			filter = qs
+			break
+		}
+	}
+
+	if filter == nil {
+		return ""
+	}
+	bs, err := bson.MarshalExtJSON(bson.D{{Key: "filter", Value: filter}}, false, false)
+	if err != nil {
+		return ""
+	}
+	// 去掉外层 {"filter": ...}
+	s := string(bs)
+	return strings.TrimSuffix(strings.TrimPrefix(s, `{"filter":`), "}")
+}
+
+// redactValue 保留文档结构，替换所有值
+func redactValue(v bson.RawValue) interface{} {
+	switch v.Type {
+	case bsontype.EmbeddedDocument:
+		elems, err := v.Document().Elements()
+		if err != nil {
+			return "?"
+		}
+		doc := make(bson.D, 0, len(elems))
+		for _, elem := range elems {
+			doc = append(doc, bson.E{Key: elem.Key(), Value: redactValue(elem.Value())})
+		}
+		return doc
+	case bsontype.Array:
+		values, err := v.Array().Values()
+		if err != nil {
+			return "?"
+		}
+		arr := make(bson.A, 0, len(values))
+		for _, item := range values {
+			arr = append(arr, redactValue(item))
+		}
+		return arr
+	default:
+		return "?"
+	}
+}
+
+// ormOp 一次 ORM 操作，结束时调用 end
+type ormOp struct {
+	// ctx 操作的上下文，包含追踪的 span，传给 Collection 的操作使集合操作的 span 嵌套在 ORM 操作下
+	ctx context.Context
+	// q 操作执行的查询条件，由操作在执行前设置，慢查询日志直接输出，不会重新生成
+	q   *Query
+	end func()
+}
+
+// observe 记录ORM操作，返回的 ormOp 需要在操作结束时调用 end
+// 对错误进行分类，上报指标、追踪，耗时超过慢查询阈值时输出查询条件
+func (orm *ORM) observe(method string, err *error) *ormOp {
+	op := &ormOp{ctx: orm.ctx}
+	if orm.db == nil {
+		op.end = func() {
+			*err = wrapError(*err)
+		}
+		return op
+	}
+	cli := orm.db.Client
+	ctx, finish := cli.observe(orm.ctx, &Operation{
+		Kind:       OperationORM,
+		Database:   orm.db.dbName,
+		Collection: orm.tableName,
+		Name:       method,
+	})
+
+	op.ctx = ctx
+	start := time.Now()
+	op.end = func() {
+		*err = wrapError(*err)
+		finish(*err)
+		elapsed := time.Since(start)
+		if cli.slowQuery <= 0 || elapsed < cli.slowQuery {
+			return
+		}
+
+		args := []interface{}{"table", orm.tableName, "method", method, "duration", elapsed}
+		if op.q != nil {
+			args = append(args, "query", op.q.JSON())
+		}
+		if *err != nil {
+			args = append(args, "error", *err)
+		}
+		cli.Logger().Warn("mongo slow query", args...)
+	}
+	return op
+}
@@ -0,0 +1,108 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+type testLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *testLogger) log(level, msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, level+" "+msg+" "+fmt.Sprint(args...))
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) { l.log("DEBUG", msg, args...) }
+func (l *testLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg, args...) }
+func (l *testLogger) Warn(msg string, args ...interface{})  { l.log("WARN", msg, args...) }
+func (l *testLogger) Error(msg string, args ...interface{}) { l.log("ERROR", msg, args...) }
+
+type testCommandHook struct {
+	events []*CommandEvent
+}
+
+func (h *testCommandHook) CommandStarted(ctx context.Context, e *CommandEvent) {
+	h.events = append(h.events, e)
+}
+
+func (h *testCommandHook) CommandSucceeded(ctx context.Context, e *CommandEvent) {
+	h.events = append(h.events, e)
+}
+
+func (h *testCommandHook) CommandFailed(ctx context.Context, e *CommandEvent) {
+	h.events = append(h.events, e)
+}
+
+func TestCommandFilter(t *testing.T) {
+	cmd, _ := bson.Marshal(bson.D{
+		{Key: "find", Value: "user"},
+		{Key: "filter", Value: bson.M{"name": "secret", "age": bson.M{"$in": bson.A{1, 2}}}},
+	})
+	filter := commandFilter(cmd)
+	if strings.Contains(filter, "secret") || !strings.Contains(filter, `"$in":["?","?"]`) {
+		t.Fatalf("filter error: %s", filter)
+	}
+	if commandCollection(cmd, "find") != "user" {
+		t.Fatal("collection error")
+	}
+
+	cmd, _ = bson.Marshal(bson.D{
+		{Key: "update", Value: "user"},
+		{Key: "updates", Value: bson.A{bson.M{"q": bson.M{"_id": 1}, "u": bson.M{"$set": bson.M{"pass": "x"}}}}},
+	})
+	if filter = commandFilter(cmd); filter != `[{"_id":"?"}]` {
+		t.Fatalf("update filter error: %s", filter)
+	}
+}
+
+func TestCommandHook(t *testing.T) {
+	m := newClientMonitor(nil)
+	hook := new(testCommandHook)
+	m.commandHooks = []CommandHook{hook}
+	monitor := m.commandMonitor()
+
+	cmd, _ := bson.Marshal(bson.D{{Key: "count", Value: "user"}, {Key: "query", Value: bson.M{"a": 1}}})
+	monitor.Started(context.Background(), &event.CommandStartedEvent{Command: cmd, DatabaseName: "test", CommandName: "count", RequestID: 1})
+	monitor.Failed(context.Background(), &event.CommandFailedEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "count", RequestID: 1, DurationNanos: int64(time.Millisecond)},
+		Failure:              "boom",
+	})
+
+	if len(hook.events) != 2 {
+		t.Fatalf("events error: %v", hook.events)
+	}
+	failed := hook.events[1]
+	if failed.Collection != "user" || failed.Filter != `{"a":"?"}` || failed.Duration != time.Millisecond || failed.Err == nil {
+		t.Fatalf("failed event error: %+v", failed)
+	}
+}
+
+func TestSlowQuery(t *testing.T) {
+	logger := new(testLogger)
+	cli := (&Client{}).SetLogger(logger).SetSlowQuery(time.Nanosecond)
+	orm := &ORM{db: &Database{Client: cli}, refConf: NewReference(), tableName: "user", keepQuery: true, Q: newMongoOrmQ()}
+	orm.Where("name", "test")
+
+	err := errors.New("boom")
+	op := orm.observe("ToData", &err)
+	op.q = orm.Cond()
+	orm.ClearCache()
+	time.Sleep(time.Millisecond)
+	op.end()
+
+	if len(logger.msgs) != 1 || !strings.Contains(logger.msgs[0], `"name":{"$eq":"test"}`) || !strings.Contains(logger.msgs[0], "boom") {
+		t.Fatalf("slow query log error: %v", logger.msgs)
+	}
+}
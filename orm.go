@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/assembly-hub/basics/util"
 	"github.com/assembly-hub/task"
@@ -37,6 +38,10 @@ type mongoOrmQ struct {
 	Limit      Limit
 	Where      Where
 	Projection Projection
+	// opts 服务端查询选项：超时、索引、排序规则、注释等
+	opts BasicQueryOptions
+	// mustFind 单条查询未找到文档时返回 ErrNotFound
+	mustFind bool
 }
 
 func newMongoOrmQ() *mongoOrmQ {
@@ -50,13 +55,38 @@ func newMongoOrmQ() *mongoOrmQ {
 	return q
 }
 
+func (q *mongoOrmQ) clone() *mongoOrmQ {
+	newQ := newMongoOrmQ()
+	newQ.Distinct = q.Distinct
+	newQ.opts = q.opts
+	newQ.mustFind = q.mustFind
+	newQ.Select = append(newQ.Select, q.Select...)
+	newQ.Order = append(newQ.Order, q.Order...)
+	newQ.Limit = append(newQ.Limit, q.Limit...)
+	for k, v := range q.Where {
+		newQ.Where[k] = v
+	}
+	for k, v := range q.Projection {
+		newQ.Projection[k] = v
+	}
+	return newQ
+}
+
 type ORM struct {
 	ctx       context.Context
 	refConf   *Reference
 	db        *Database
 	tableName string
 	keepQuery bool
-	Q         *mongoOrmQ
+	immutable bool
+	unscoped  bool
+	// concern 读偏好、读写关注
+	concern *CollectionOptions
+	// tracker 脏数据跟踪，见 Track
+	tracker *tracker
+	// err 创建时的错误，如多租户路由缺少租户，所有操作返回该错误
+	err error
+	Q   *mongoOrmQ
 }
 
 type Paging struct {
@@ -71,6 +101,9 @@ func NewORMByDB(ctx context.Context, db *Database, tbName string, ref *Reference
 	if ctx != nil {
 		ctxObj = ctx
 	}
+	if sessionCtx, ok := ctx.(SessionContext); ok {
+		db = db.WithSession(sessionCtx)
+	}
 
 	return &ORM{
 		ctx:       ctxObj,
@@ -82,22 +115,46 @@ func NewORMByDB(ctx context.Context, db *Database, tbName string, ref *Reference
 	}
 }
 
+// NewORMByClient 创建ORM，开启多租户数据库路由时，根据 ctx 中的租户选择数据库
+// ctx 中缺少租户时，后续操作返回 ErrTenantRequired
 func NewORMByClient(ctx context.Context, cli *Client, dbName, tbName string, ref *Reference) *ORM {
 	ctxObj := cli.ctx
 	if ctx != nil {
 		ctxObj = ctx
 	}
 
+	// 路由失败时不能回退到共享数据库，否则会跨租户读写
+	db, err := cli.TenantDatabase(ctxObj, dbName)
+	if err != nil {
+		// 共享数据库的所有操作返回路由错误
+		db = cli.Database(dbName)
+		db.optsErr = err
+	}
+	if sessionCtx, ok := ctx.(SessionContext); ok {
+		db = db.WithSession(sessionCtx)
+	}
+
 	return &ORM{
 		ctx:       ctxObj,
 		refConf:   ref,
 		keepQuery: true,
-		db:        cli.Database(dbName),
+		db:        db,
 		tableName: tbName,
+		err:       err,
 		Q:         newMongoOrmQ(),
 	}
 }
 
+// WithSession 返回绑定事务上下文的ORM副本，查询条件一并复制
+// 在 NewSession 回调中使用，保证操作（包括外键子查询）在事务内执行
+func (orm *ORM) WithSession(sessionCtx SessionContext) *ORM {
+	newOrm := *orm
+	newOrm.ctx = sessionCtx
+	newOrm.db = orm.db.WithSession(sessionCtx)
+	newOrm.Q = orm.Q.clone()
+	return &newOrm
+}
+
 // Query 条件对
 // "id__gt", 1, "name": "test"
 func (orm *ORM) Query(pair ...interface{}) *ORM {
@@ -108,15 +165,17 @@ func (orm *ORM) Query(pair ...interface{}) *ORM {
 		return orm
 	}
 
+	o := orm.builder()
 	for i, n := 0, len(pair)/2; i < n; i++ {
-		orm.Q.Where[util.Any2String(pair[i*2])] = pair[i*2+1]
+		o.Q.Where[util.Any2String(pair[i*2])] = pair[i*2+1]
 	}
-	return orm
+	return o
 }
 
 func (orm *ORM) OverLimit(over, size uint) *ORM {
-	orm.Q.Limit = Limit{over, size}
-	return orm
+	o := orm.builder()
+	o.Q.Limit = Limit{over, size}
+	return o
 }
 
 func (orm *ORM) Page(pageNo, pageSize uint) *ORM {
@@ -127,109 +186,232 @@ func (orm *ORM) Page(pageNo, pageSize uint) *ORM {
 		panic("page no must be gt 0")
 	}
 
-	orm.Q.Limit = Limit{pageSize * (pageNo - 1), pageSize}
-	return orm
+	o := orm.builder()
+	o.Q.Limit = Limit{pageSize * (pageNo - 1), pageSize}
+	return o
 }
 
 func (orm *ORM) Limit(size uint) *ORM {
-	orm.Q.Limit = Limit{size}
-	return orm
+	o := orm.builder()
+	o.Q.Limit = Limit{size}
+	return o
 }
 
 func (orm *ORM) Distinct(b bool) *ORM {
-	orm.Q.Distinct = b
-	return orm
+	o := orm.builder()
+	o.Q.Distinct = b
+	return o
+}
+
+// MustFind ToData 查询单条数据（struct、map、单个字段）未找到文档时返回 ErrNotFound
+// 默认返回 nil 且不修改 target，列表查询不受影响
+func (orm *ORM) MustFind() *ORM {
+	o := orm.builder()
+	o.Q.mustFind = true
+	return o
 }
 
 func (orm *ORM) KeepQuery(b bool) *ORM {
-	orm.keepQuery = b
-	return orm
+	o := orm.builder()
+	o.keepQuery = b
+	return o
 }
 
 func (orm *ORM) Where(col string, value interface{}) *ORM {
-	orm.Q.Where[col] = value
-	return orm
+	o := orm.builder()
+	o.Q.Where[col] = value
+	return o
 }
 
 func (orm *ORM) Wheres(where Where) *ORM {
+	o := orm.builder()
 	for k, v := range where {
-		orm.Q.Where[k] = v
+		o.Q.Where[k] = v
 	}
-	return orm
+	return o
 }
 
 func (orm *ORM) Projection(col string, value interface{}) *ORM {
-	orm.Q.Projection[col] = value
-	return orm
+	o := orm.builder()
+	o.Q.Projection[col] = value
+	return o
 }
 
 func (orm *ORM) Projections(where Where) *ORM {
+	o := orm.builder()
 	for k, v := range where {
-		orm.Q.Projection[k] = v
+		o.Q.Projection[k] = v
 	}
-	return orm
+	return o
 }
 
 func (orm *ORM) Select(cols ...string) *ORM {
-	orm.Q.Select = append(orm.Q.Select, cols...)
-	return orm
+	o := orm.builder()
+	for _, col := range cols {
+		if _, _, ok := parseColumn(col); ok {
+			o.Q.Select = append(o.Q.Select, strings.TrimSpace(col))
+		}
+	}
+	return o
 }
 
 func (orm *ORM) Order(cols ...string) *ORM {
-	orm.Q.Order = append(orm.Q.Order, cols...)
-	return orm
+	o := orm.builder()
+	for _, col := range cols {
+		if _, _, ok := parseColumn(col); ok {
+			o.Q.Order = append(o.Q.Order, strings.TrimSpace(col))
+		}
+	}
+	return o
+}
+
+// MaxTime 服务端最大执行时间
+func (orm *ORM) MaxTime(d time.Duration) *ORM {
+	o := orm.builder()
+	o.Q.opts.MaxTime(d)
+	return o
+}
+
+// Hint 按索引名称指定索引
+func (orm *ORM) Hint(indexName string) *ORM {
+	o := orm.builder()
+	o.Q.opts.Hint(indexName)
+	return o
 }
 
+// HintKeys 按索引字段指定索引，如：HintKeys("key1", "-key2")
+func (orm *ORM) HintKeys(cols ...string) *ORM {
+	o := orm.builder()
+	o.Q.opts.HintKeys(cols...)
+	return o
+}
+
+// Collation 设置排序规则，如：Collation("zh", CollationStrengthSecondary)
+func (orm *ORM) Collation(locale string, strength int) *ORM {
+	o := orm.builder()
+	o.Q.opts.Collation(locale, strength)
+	return o
+}
+
+// Comment 查询注释，便于在慢查询日志中定位
+func (orm *ORM) Comment(comment string) *ORM {
+	o := orm.builder()
+	o.Q.opts.Comment(comment)
+	return o
+}
+
+func (orm *ORM) AllowDiskUse(b bool) *ORM {
+	o := orm.builder()
+	o.Q.opts.AllowDiskUse(b)
+	return o
+}
+
+func (orm *ORM) BatchSize(n int32) *ORM {
+	o := orm.builder()
+	o.Q.opts.BatchSize(n)
+	return o
+}
+
+func (orm *ORM) NoCursorTimeout(b bool) *ORM {
+	o := orm.builder()
+	o.Q.opts.NoCursorTimeout(b)
+	return o
+}
+
+// ClearCache 清空查询条件，不可变模式下返回新的ORM，原ORM不变
 func (orm *ORM) ClearCache() *ORM {
+	if orm.immutable {
+		o := *orm
+		o.Q = newMongoOrmQ()
+		return &o
+	}
 	orm.Q = newMongoOrmQ()
 	return orm
 }
 
+// Immutable 设置不可变模式
+// 开启后 Where、Order、Limit 等方法均返回新的ORM，原ORM不变，可在多个goroutine间共享基础查询
+func (orm *ORM) Immutable(b bool) *ORM {
+	o := orm.builder()
+	o.immutable = b
+	return o
+}
+
+// Clone 复制ORM及查询条件，用于从基础查询派生新的查询
+func (orm *ORM) Clone() *ORM {
+	o := *orm
+	o.Q = orm.Q.clone()
+	return &o
+}
+
+func (orm *ORM) builder() *ORM {
+	if orm.immutable {
+		return orm.Clone()
+	}
+	return orm
+}
+
+// Cond 查询条件，包含默认作用域
 func (orm *ORM) Cond() *Query {
-	return MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+	return orm.scoped().cond()
 }
 
 func (orm *ORM) ToJSON() string {
-	q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+	q := orm.Cond()
 	return q.JSON()
 }
 
+func (orm *ORM) cond() *Query {
+	return MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+}
+
 // Exist 检查数据是否存在
-func (orm *ORM) Exist() (bool, error) {
+func (orm *ORM) Exist() (exist bool, err error) {
+	op := orm.observe("Exist", &err)
+	defer op.end()
 	if !orm.keepQuery {
 		defer func() {
 			orm.ClearCache()
 		}()
 	}
 
-	table := orm.db.Collection(orm.tableName)
-	q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+	table, err := orm.table()
+	if err != nil {
+		return false, err
+	}
+	o := orm.scoped()
+	q := o.cond()
+	op.q = q
 	opts := NewFindOneOptions()
+	opts.BasicQueryOptions = o.Q.opts
 	opts.Select(Select{"_id"})
-	if len(orm.Q.Limit) == 1 {
-		opts.Skip(int64(orm.Q.Limit[0]))
+	if len(o.Q.Limit) == 1 {
+		opts.Skip(int64(o.Q.Limit[0]))
 	}
-	var target map[string]string
-	err := table.FindOne(orm.ctx, q, &target, opts)
+	var target map[string]interface{}
+	err = table.FindOne(op.ctx, q, &target, opts)
 	if err != nil {
 		return false, err
 	}
 
-	if target != nil && target["_id"] != "" {
+	if target != nil && target["_id"] != nil {
 		return true, nil
 	}
 
 	return false, nil
 }
 
-func (orm *ORM) PageData(target interface{}, pageNo, pageSize uint) (*Paging, error) {
-	totalCount, err := orm.Count(false)
+func (orm *ORM) PageData(target interface{}, pageNo, pageSize uint) (pg *Paging, err error) {
+	// Count、ToData 不再单独记录
+	op := orm.observe("PageData", &err)
+	defer op.end()
+	totalCount, err := orm.count(op, false)
 	if err != nil {
 		return nil, err
 	}
 
 	if pageNo == 0 || pageSize == 0 {
-		return nil, fmt.Errorf("page no page size need gt 0")
+		return nil, fmt.Errorf("%w: page no page size need gt 0", ErrInvalidQuery)
 	}
 
 	totalPage := totalCount / int64(pageSize)
@@ -243,9 +425,7 @@ func (orm *ORM) PageData(target interface{}, pageNo, pageSize uint) (*Paging, er
 	if pageNo < 1 {
 		pageNo = 1
 	}
-	orm.Page(pageNo, pageSize)
-
-	err = orm.ToData(target)
+	err = orm.Page(pageNo, pageSize).toData(op, target)
 	if err != nil {
 		return nil, err
 	}
@@ -268,6 +448,8 @@ func (orm *ORM) setDataFunc(dataVal reflect.Value, v interface{}) (err error) {
 
 	val := fmt.Sprintf("%v", v)
 	switch v := v.(type) {
+	case nil:
+		// null 保持零值
 	case string:
 		dataVal.SetString(v)
 	case int, int8, int16, int32, int64:
@@ -292,6 +474,39 @@ func (orm *ORM) setDataFunc(dataVal reflect.Value, v interface{}) (err error) {
 		dataVal.SetBytes(v)
 	case primitive.ObjectID:
 		dataVal.SetString(v.Hex())
+	case primitive.Binary:
+		// subtype 3/4 只在目标为 UUID 或 string 时转换，其他目标（如：primitive.Binary、interface{}）保留原始数据
+		if dataVal.Type() == tUUID || dataVal.Kind() == reflect.String {
+			if u, ok := binaryUUID(v); ok {
+				return orm.setDataFunc(dataVal, u)
+			}
+		}
+		dataVal.Set(reflect.ValueOf(v))
+	case UUID:
+		if dataVal.Kind() == reflect.String {
+			dataVal.SetString(v.String())
+		} else {
+			dataVal.Set(reflect.ValueOf(v))
+		}
+	case primitive.Decimal128:
+		return orm.setDataFunc(dataVal, Decimal(v))
+	case Decimal:
+		switch dataVal.Kind() {
+		case reflect.String:
+			dataVal.SetString(v.String())
+		case reflect.Float32, reflect.Float64:
+			f64, err := v.Float64()
+			if err != nil {
+				return err
+			}
+			dataVal.SetFloat(f64)
+		default:
+			if dataVal.Type() == tDecimal128 {
+				dataVal.Set(reflect.ValueOf(primitive.Decimal128(v)))
+			} else {
+				dataVal.Set(reflect.ValueOf(v))
+			}
+		}
 	default:
 		dataVal.Set(reflect.ValueOf(v))
 	}
@@ -355,37 +570,47 @@ func (orm *ORM) mongoDataFormat(arr primitive.A) (ret interface{}, err error) {
 	return ret, nil
 }
 
-func (orm *ORM) toListData(target interface{}, dataValue *reflect.Value, table *Collection) (err error) {
+// isDocument 结构体、map 按文档解码，Decimal 等值类型按单字段解码
+func isDocument(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == tDecimal || t == tDecimal128 {
+		return false
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+}
+
+func (orm *ORM) toListData(op *ormOp, target interface{}, dataValue *reflect.Value, table *Collection) (err error) {
 	elemType := dataValue.Type().Elem()
-	if elemType.Kind() == reflect.Struct || elemType.Kind() == reflect.Map ||
-		(elemType.Kind() == reflect.Ptr && (elemType.Elem().Kind() == reflect.Struct || elemType.Elem().Kind() == reflect.Map)) {
+	if isDocument(elemType) {
 		if orm.Q.Distinct {
-			return fmt.Errorf("distinct only support simple data array, such as []number, []string")
+			return fmt.Errorf("%w: distinct only support simple data array, such as []number, []string", ErrInvalidQuery)
 		}
 
-		q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
-		opts := NewFindOptions()
-		opts.Select(orm.Q.Select)
-		if len(orm.Q.Limit) == 1 {
-			opts.Limit(int64(orm.Q.Limit[0]))
-		} else if len(orm.Q.Limit) == 2 {
-			opts.Skip(int64(orm.Q.Limit[0]))
-			opts.Limit(int64(orm.Q.Limit[1]))
-		}
-		opts.Projection(orm.Q.Projection)
-		opts.Sort(orm.Q.Order)
-		err = table.FindDocs(orm.ctx, q, target, opts)
+		q := orm.cond()
+		op.q = q
+		opts := orm.findOptions()
+		start := time.Now()
+		err = table.FindDocs(op.ctx, q, target, opts)
 		if err != nil {
 			return err
 		}
+		orm.warnCollScan(op.ctx, table, q, opts, time.Since(start))
 	} else {
 		if len(orm.Q.Select) != 1 {
-			return fmt.Errorf("must be select one field data")
+			return fmt.Errorf("%w: must be select one field data", ErrInvalidQuery)
 		}
 
 		if orm.Q.Distinct {
-			q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
-			result, err := table.Distinct(orm.ctx, orm.Q.Select[0], q, nil)
+			q := orm.cond()
+			op.q = q
+			opts := NewDistinctOptions()
+			opts.BasicQueryOptions = orm.Q.opts
+			if opts.maxTime == nil {
+				opts.MaxTime(5 * time.Second)
+			}
+			result, err := table.DistinctWithOptions(op.ctx, orm.Q.Select[0], q, opts)
 			if err != nil {
 				return err
 			}
@@ -410,22 +635,16 @@ func (orm *ORM) toListData(target interface{}, dataValue *reflect.Value, table *
 			return nil
 		}
 
-		q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
-		opts := NewFindOptions()
-		opts.Select(orm.Q.Select)
-		if len(orm.Q.Limit) == 1 {
-			opts.Limit(int64(orm.Q.Limit[0]))
-		} else if len(orm.Q.Limit) == 2 {
-			opts.Skip(int64(orm.Q.Limit[0]))
-			opts.Limit(int64(orm.Q.Limit[1]))
-		}
-		opts.Projection(orm.Q.Projection)
-		opts.Sort(orm.Q.Order)
+		q := orm.cond()
+		op.q = q
+		opts := orm.findOptions()
+		start := time.Now()
 		var ret []map[string]interface{}
-		err = table.FindDocs(orm.ctx, q, &ret, opts)
+		err = table.FindDocs(op.ctx, q, &ret, opts)
 		if err != nil {
 			return err
 		}
+		orm.warnCollScan(op.ctx, table, q, opts, time.Since(start))
 
 		if len(ret) <= 0 {
 			return nil
@@ -460,6 +679,12 @@ func (orm *ORM) toListData(target interface{}, dataValue *reflect.Value, table *
 }
 
 func (orm *ORM) ToData(target interface{}) (err error) {
+	op := orm.observe("ToData", &err)
+	defer op.end()
+	return orm.toData(op, target)
+}
+
+func (orm *ORM) toData(op *ormOp, target interface{}) (err error) {
 	if !orm.keepQuery {
 		defer func() {
 			orm.ClearCache()
@@ -471,47 +696,68 @@ func (orm *ORM) ToData(target interface{}) (err error) {
 		return ErrTargetNotSettable
 	}
 
-	table := orm.db.Collection(orm.tableName)
+	table, err := orm.table()
+	if err != nil {
+		return err
+	}
 
+	o := orm.scoped()
 	dataValue = dataValue.Elem()
+	if orm.tracker != nil {
+		defer func() {
+			if err == nil {
+				err = orm.tracker.snapshot(orm.db.codecRegistry(), dataValue)
+			}
+		}()
+	}
 	if dataValue.Type().Kind() == reflect.Slice {
-		return orm.toListData(target, &dataValue, table)
-	} else if dataValue.Type().Kind() == reflect.Map || dataValue.Type().Kind() == reflect.Struct {
-		if orm.Q.Distinct {
-			return fmt.Errorf("distinct only support simple data array, such as []number, []string")
+		return o.toListData(op, target, &dataValue, table)
+	} else if isDocument(dataValue.Type()) {
+		if o.Q.Distinct {
+			return fmt.Errorf("%w: distinct only support simple data array, such as []number, []string", ErrInvalidQuery)
 		}
 
-		q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+		q := o.cond()
+		op.q = q
 		opts := NewFindOneOptions()
-		opts.Select(orm.Q.Select)
-		if len(orm.Q.Limit) == 1 {
-			opts.Skip(int64(orm.Q.Limit[0]))
+		opts.BasicQueryOptions = o.Q.opts
+		opts.Select(o.Q.Select)
+		if len(o.Q.Limit) == 1 {
+			opts.Skip(int64(o.Q.Limit[0]))
+		}
+		opts.Projection(o.Q.Projection)
+		opts.Sort(o.Q.Order)
+		if o.Q.mustFind {
+			opts.ErrorOnMissing()
 		}
-		opts.Projection(orm.Q.Projection)
-		opts.Sort(orm.Q.Order)
-		err = table.FindOne(orm.ctx, q, target, opts)
+		err = table.FindOne(op.ctx, q, target, opts)
 		if err != nil {
 			return err
 		}
 	} else {
-		if orm.Q.Distinct {
-			return fmt.Errorf("distinct only support simple data array, such as []number, []string")
+		if o.Q.Distinct {
+			return fmt.Errorf("%w: distinct only support simple data array, such as []number, []string", ErrInvalidQuery)
 		}
 
-		if len(orm.Q.Select) != 1 {
-			return fmt.Errorf("must be select one field data")
+		if len(o.Q.Select) != 1 {
+			return fmt.Errorf("%w: must be select one field data", ErrInvalidQuery)
 		}
-		q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+		q := o.cond()
+		op.q = q
 		opts := NewFindOneOptions()
-		opts.Select(orm.Q.Select)
-		if len(orm.Q.Limit) == 1 {
-			opts.Skip(int64(orm.Q.Limit[0]))
+		opts.BasicQueryOptions = o.Q.opts
+		opts.Select(o.Q.Select)
+		if len(o.Q.Limit) == 1 {
+			opts.Skip(int64(o.Q.Limit[0]))
+		}
+		opts.Projection(o.Q.Projection)
+		opts.Sort(o.Q.Order)
+		if o.Q.mustFind {
+			opts.ErrorOnMissing()
 		}
-		opts.Projection(orm.Q.Projection)
-		opts.Sort(orm.Q.Order)
 
 		var ret map[string]interface{}
-		err = table.FindOne(orm.ctx, q, &ret, opts)
+		err = table.FindOne(op.ctx, q, &ret, opts)
 		if err != nil {
 			return err
 		}
@@ -525,7 +771,7 @@ func (orm *ORM) ToData(target interface{}) (err error) {
 			return err
 		}
 
-		subKey := strings.Split(orm.Q.Select[0], ".")
+		subKey := strings.Split(o.Q.Select[0], ".")
 		var temp = ret
 		for _, k := range subKey[:len(subKey)-1] {
 			temp = temp[k].(map[string]interface{})
@@ -565,84 +811,104 @@ func (orm *ORM) formatWhereArr(tbName string, where interface{}) interface{} {
 	return newWhere
 }
 
+// formatWhere 格式化查询条件，外键条件转换为 refQ
+// 返回新的条件对象，不修改传入的 raw
 func (orm *ORM) formatWhere(tbName string, raw Where) Where {
-	var needHandleKeyList []string
+	where := make(Where, len(raw))
+	var refKeyList []string
+	var refCondList []*tempRefQ
+	kt := orm.refConf.getPrimaryKey(tbName)
 	for k, v := range raw {
 		if util.ElemIn(k, []string{"$and", "$or", "$nor"}) {
-			raw[k] = orm.formatWhereArr(tbName, v)
-		} else {
-			realKey := k
-			if k[0] == '~' {
-				realKey = k[1:]
-			}
+			where[k] = orm.formatWhereArr(tbName, v)
+			continue
+		}
 
-			r := orm.refConf.getRef(tbName, realKey)
-			if r != nil {
-				if _, ok := v.(map[string]interface{}); !ok {
-					if _, ok := v.(*refQ); !ok {
-						panic(fmt.Sprintf("ref key[%s] condition type must be map[string]interface{}", k))
-					} else {
-						continue
-					}
-				}
-				cond := orm.formatWhere(r.To, v.(map[string]interface{}))
-				raw[k] = &tempRefQ{
-					From:  tbName,
-					Query: cond,
+		where[k] = v
+		realKey := k
+		if k[0] == '~' {
+			realKey = k[1:]
+		}
+		if realKey == "_id" || strings.HasPrefix(realKey, "_id__") {
+			where[k] = kt.formatKeyCond(realKey, v)
+			continue
+		}
+
+		r := orm.refConf.getRef(tbName, realKey)
+		if r != nil {
+			if _, ok := v.(map[string]interface{}); !ok {
+				if _, ok := v.(*refQ); !ok {
+					panic(fmt.Sprintf("ref key[%s] condition type must be map[string]interface{}", k))
+				} else {
+					continue
 				}
-				needHandleKeyList = append(needHandleKeyList, k)
 			}
+			cond := orm.formatWhere(r.To, orm.scopedWhere(r.To, v.(map[string]interface{})))
+			refKeyList = append(refKeyList, k)
+			refCondList = append(refCondList, &tempRefQ{
+				From:  tbName,
+				Query: cond,
+			})
 		}
 	}
-	if len(needHandleKeyList) == 1 {
-		k := needHandleKeyList[0]
-		if data, ok := raw[k].(*tempRefQ); ok {
-			raw[k] = &refQ{
-				Ref:   orm.refConf,
-				From:  data.From,
-				DB:    orm.db,
-				Query: MixQ(data.Query),
-			}
-		} else {
-			panic("ref query type error")
+	if len(refKeyList) == 1 {
+		where[refKeyList[0]] = &refQ{
+			Ctx:   orm.ctx,
+			Ref:   orm.refConf,
+			From:  refCondList[0].From,
+			DB:    orm.db,
+			Query: MixQ(refCondList[0].Query),
 		}
-	} else if len(needHandleKeyList) > 1 {
+	} else if len(refKeyList) > 1 {
+		refList := make([]*refQ, len(refKeyList))
 		condExecutor := task.NewTaskExecutor(" mongotool query")
-		for _, k := range needHandleKeyList {
+		for i := range refKeyList {
 			condExecutor.AddFixed(func(param ...interface{}) (interface{}, error) {
-				cond := param[0].(Where)
-				key := param[1].(string)
-				if data, ok := cond[key].(*tempRefQ); ok {
-					cond[key] = &refQ{
-						Ref:   orm.refConf,
-						From:  data.From,
-						DB:    orm.db,
-						Query: MixQ(data.Query),
-					}
-				} else {
-					return nil, fmt.Errorf("ref query type error")
+				idx := param[0].(int)
+				data := param[1].(*tempRefQ)
+				refList[idx] = &refQ{
+					Ctx:   orm.ctx,
+					Ref:   orm.refConf,
+					From:  data.From,
+					DB:    orm.db,
+					Query: MixQ(data.Query),
 				}
 				return nil, nil
-			}, raw, k)
+			}, i, refCondList[i])
 		}
 		_, err := condExecutor.Execute(orm.ctx)
 		if err != nil {
 			panic(err)
 		}
+		for i, k := range refKeyList {
+			where[k] = refList[i]
+		}
 	}
-	return raw
+	return where
+}
+
+func (orm *ORM) Count(clearCache bool) (count int64, err error) {
+	op := orm.observe("Count", &err)
+	defer op.end()
+	return orm.count(op, clearCache)
 }
 
-func (orm *ORM) Count(clearCache bool) (int64, error) {
+func (orm *ORM) count(op *ormOp, clearCache bool) (count int64, err error) {
 	if clearCache {
 		defer func() {
 			orm.ClearCache()
 		}()
 	}
-	table := orm.db.Collection(orm.tableName)
-	q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+	table, err := orm.table()
+	if err != nil {
+		return 0, err
+	}
+	q := orm.Cond()
+	op.q = q
+	opts := NewCount()
+	opts.BasicQueryOptions = orm.Q.opts
 
-	count, err := table.Count(orm.ctx, q, nil)
+	count, err = table.Count(op.ctx, q, opts)
 	if err != nil {
 		return 0, err
 	}
@@ -650,8 +916,14 @@ func (orm *ORM) Count(clearCache bool) (int64, error) {
 	return count, nil
 }
 
-func (orm *ORM) InsertOne(data interface{}) (string, error) {
-	table := orm.db.Collection(orm.tableName)
+// InsertOne 添加一条数据，_id 按表的主键类型处理，返回主键，ObjectID 为 hex 字符串
+func (orm *ORM) InsertOne(data interface{}) (id interface{}, err error) {
+	op := orm.observe("InsertOne", &err)
+	defer op.end()
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
 	var m map[string]interface{}
 	switch data := data.(type) {
 	case map[string]interface{}:
@@ -663,32 +935,22 @@ func (orm *ORM) InsertOne(data interface{}) (string, error) {
 		m = Struct2Map(data)
 	}
 
-	if id, ok := m["_id"]; ok {
-		switch id := id.(type) {
-		case string:
-			if id == "" {
-				delete(m, "_id")
-			} else {
-				m["_id"] = TryString2ObjectID(id)
-			}
-		case ObjectID:
-			if id.IsZero() {
-				delete(m, "_id")
-			}
-		case [12]byte:
-			var objID ObjectID
-			copy(objID[:], id[:])
-			if objID.IsZero() {
-				delete(m, "_id")
-			}
-		}
+	if err = orm.refConf.getPrimaryKey(orm.tableName).formatInsertID(m); err != nil {
+		return nil, err
 	}
-	return table.InsertDoc(orm.ctx, m)
+	return table.InsertDoc(op.ctx, m)
 }
 
-func (orm *ORM) InsertMany(data []interface{}, ordered bool) ([]string, error) {
-	table := orm.db.Collection(orm.tableName)
+// InsertMany 添加多条数据，返回的主键同 InsertOne
+func (orm *ORM) InsertMany(data []interface{}, ordered bool) (ids []interface{}, err error) {
+	op := orm.observe("InsertMany", &err)
+	defer op.end()
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
 
+	kt := orm.refConf.getPrimaryKey(orm.tableName)
 	var insertDataList []interface{}
 	for _, v := range data {
 		var m map[string]interface{}
@@ -702,140 +964,194 @@ func (orm *ORM) InsertMany(data []interface{}, ordered bool) ([]string, error) {
 			m = Struct2Map(v)
 		}
 
-		if id, ok := m["_id"]; ok {
-			switch id := id.(type) {
-			case string:
-				if id == "" {
-					delete(m, "_id")
-				} else {
-					m["_id"] = TryString2ObjectID(id)
-				}
-			case ObjectID:
-				if id.IsZero() {
-					delete(m, "_id")
-				}
-			case [12]byte:
-				var objID ObjectID
-				copy(objID[:], id[:])
-				if objID.IsZero() {
-					delete(m, "_id")
-				}
-			}
+		if err = kt.formatInsertID(m); err != nil {
+			return nil, err
 		}
 		insertDataList = append(insertDataList, m)
 	}
 
-	return table.InsertDocs(orm.ctx, insertDataList, ordered)
+	return table.InsertDocs(op.ctx, insertDataList, ordered)
 }
 
-func (orm *ORM) UpdateOne(data map[string]interface{}, upsert bool) (*UpdateResult, error) {
+func (orm *ORM) UpdateOne(data map[string]interface{}, upsert bool) (ret *UpdateResult, err error) {
+	op := orm.observe("UpdateOne", &err)
+	defer op.end()
 	if !orm.keepQuery {
 		defer func() {
 			orm.ClearCache()
 		}()
 	}
 
-	table := orm.db.Collection(orm.tableName)
-	q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
+	q := orm.Cond()
+	op.q = q
 	opt := NewUpdate()
 	opt.Upsert(upsert)
-	return table.UpdateOne(orm.ctx, q, data, opt)
+	return table.UpdateOne(op.ctx, q, data, opt)
 }
 
-func (orm *ORM) UpdateMany(data map[string]interface{}, upsert bool) (*UpdateResult, error) {
+func (orm *ORM) UpdateMany(data map[string]interface{}, upsert bool) (ret *UpdateResult, err error) {
+	op := orm.observe("UpdateMany", &err)
+	defer op.end()
 	if !orm.keepQuery {
 		defer func() {
 			orm.ClearCache()
 		}()
 	}
 
-	table := orm.db.Collection(orm.tableName)
-	q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
+	q := orm.Cond()
+	op.q = q
 	opt := NewUpdate()
 	opt.Upsert(upsert)
-	return table.UpdateMany(orm.ctx, q, data, opt)
+	return table.UpdateMany(op.ctx, q, data, opt)
 }
 
-func (orm *ORM) UpdateOneCustom(update updateType, data map[string]interface{}, upsert bool) (*UpdateResult, error) {
+func (orm *ORM) UpdateOneCustom(update updateType, data map[string]interface{}, upsert bool) (ret *UpdateResult, err error) {
+	op := orm.observe("UpdateOneCustom", &err)
+	defer op.end()
 	if !orm.keepQuery {
 		defer func() {
 			orm.ClearCache()
 		}()
 	}
 
-	table := orm.db.Collection(orm.tableName)
-	q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
+	q := orm.Cond()
+	op.q = q
 	opt := NewUpdate()
 	opt.Upsert(upsert)
-	return table.UpdateOneCustom(orm.ctx, q, update, data, opt)
+	return table.UpdateOneCustom(op.ctx, q, update, data, opt)
 }
 
-func (orm *ORM) UpdateManyCustom(update updateType, data map[string]interface{}, upsert bool) (*UpdateResult, error) {
+func (orm *ORM) UpdateManyCustom(update updateType, data map[string]interface{}, upsert bool) (ret *UpdateResult, err error) {
+	op := orm.observe("UpdateManyCustom", &err)
+	defer op.end()
 	if !orm.keepQuery {
 		defer func() {
 			orm.ClearCache()
 		}()
 	}
 
-	table := orm.db.Collection(orm.tableName)
-	q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
+	q := orm.Cond()
+	op.q = q
 	opt := NewUpdate()
 	opt.Upsert(upsert)
-	return table.UpdateManyCustom(orm.ctx, q, update, data, opt)
+	return table.UpdateManyCustom(op.ctx, q, update, data, opt)
 }
 
-func (orm *ORM) DeleteOne() (*DeleteResult, error) {
+func (orm *ORM) DeleteOne() (ret *DeleteResult, err error) {
+	op := orm.observe("DeleteOne", &err)
+	defer op.end()
 	if !orm.keepQuery {
 		defer func() {
 			orm.ClearCache()
 		}()
 	}
 
-	table := orm.db.Collection(orm.tableName)
-	q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
-	return table.DeleteOne(orm.ctx, q)
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
+	q := orm.Cond()
+	op.q = q
+	return table.DeleteOne(op.ctx, q)
 }
 
-func (orm *ORM) DeleteMany() (*DeleteResult, error) {
+func (orm *ORM) DeleteMany() (ret *DeleteResult, err error) {
+	op := orm.observe("DeleteMany", &err)
+	defer op.end()
 	if !orm.keepQuery {
 		defer func() {
 			orm.ClearCache()
 		}()
 	}
 
-	table := orm.db.Collection(orm.tableName)
-	q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
-	return table.DeleteMany(orm.ctx, q)
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
+	q := orm.Cond()
+	op.q = q
+	return table.DeleteMany(op.ctx, q)
 }
 
-func (orm *ORM) BulkWrite(bwm *BulkWriteModel) (*BulkWriteResult, error) {
-	table := orm.db.Collection(orm.tableName)
-	return table.BulkWrite(orm.ctx, bwm)
+// BulkWrite 批量写入，bwm 未设置主键类型时按表的主键类型处理插入文档的 _id
+func (orm *ORM) BulkWrite(bwm *BulkWriteModel) (ret *BulkWriteResult, err error) {
+	op := orm.observe("BulkWrite", &err)
+	defer op.end()
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
+	if bwm, err = bwm.withKeyType(orm.refConf.getPrimaryKey(orm.tableName)); err != nil {
+		return nil, err
+	}
+	return table.BulkWrite(op.ctx, bwm)
 }
 
-func (orm *ORM) ReplaceOne(data map[string]interface{}, upsert bool) (*UpdateResult, error) {
+func (orm *ORM) ReplaceOne(data map[string]interface{}, upsert bool) (ret *UpdateResult, err error) {
+	op := orm.observe("ReplaceOne", &err)
+	defer op.end()
 	if !orm.keepQuery {
 		defer func() {
 			orm.ClearCache()
 		}()
 	}
 
-	table := orm.db.Collection(orm.tableName)
-	q := MixQ(orm.formatWhere(orm.tableName, orm.Q.Where))
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
+	q := orm.Cond()
+	op.q = q
 	opt := NewReplace()
 	opt.Upsert(upsert)
-	return table.ReplaceOne(orm.ctx, q, data, opt)
+	return table.ReplaceOne(op.ctx, q, data, opt)
 }
 
 func (orm *ORM) Client() *Client {
 	return orm.db.Client
 }
 
+func (orm *ORM) table() (*Collection, error) {
+	if orm.err != nil {
+		return nil, orm.err
+	}
+	if err := orm.db.checkSession(orm.ctx, orm.tableName); err != nil {
+		return nil, err
+	}
+	return orm.Collection(), nil
+}
+
+// Err 创建 ORM 时的错误，如：多租户路由缺少租户，ORM 的所有操作返回该错误
+func (orm *ORM) Err() error {
+	return orm.err
+}
+
+// Collection ORM 对应的集合，创建 ORM 失败（如缺少租户）时集合的操作返回 Err()
 func (orm *ORM) Collection() *Collection {
 	table := orm.db.Collection(orm.tableName)
+	if orm.concern != nil {
+		table = table.WithOptions(orm.concern)
+	}
 	return table
 }
 
+// Database ORM 对应的数据库，创建 ORM 失败（如缺少租户）时由其创建的集合的操作返回 Err()
 func (orm *ORM) Database() *Database {
 	return orm.db
 }
@@ -0,0 +1,270 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestORMImmutable(t *testing.T) {
+	base := &ORM{ctx: context.Background(), keepQuery: true, tableName: "test1", Q: newMongoOrmQ()}
+	base = base.Immutable(true).Where("txt", "1")
+
+	q1 := base.Where("name", "a").Order("-txt").Limit(10)
+	q2 := base.Where("name", "b")
+
+	if len(base.Q.Where) != 1 || len(base.Q.Order) != 0 || len(base.Q.Limit) != 0 {
+		t.Fatalf("base query changed: %+v", base.Q)
+	}
+	if q1.Q.Where["name"] != "a" || q2.Q.Where["name"] != "b" {
+		t.Fatalf("forked query error: %v, %v", q1.Q.Where, q2.Q.Where)
+	}
+	if len(q1.Q.Order) != 1 || len(q1.Q.Limit) != 1 {
+		t.Fatalf("q1 query error: %+v", q1.Q)
+	}
+}
+
+func TestFormatWhereNotMutate(t *testing.T) {
+	ref := NewReference()
+	ref.AddTableDef("test1", tb1{})
+	ref.AddTableDef("test2", tb2{})
+	ref.AddTableDef("test3", tb3{})
+	ref.BuildRefs()
+
+	orm := &ORM{ctx: context.Background(), refConf: ref, tableName: "test1", Q: newMongoOrmQ()}
+	where := Where{
+		"txt": "1",
+		"ref": RefWhere{
+			"name": "test",
+		},
+		"$or": []interface{}{
+			map[string]interface{}{
+				"ref2": RefWhere{"txt": "1"},
+			},
+		},
+	}
+
+	formatted := orm.formatWhere(orm.tableName, where)
+	if _, ok := formatted["ref"].(*refQ); !ok {
+		t.Fatalf("ref condition must be converted to refQ, got %T", formatted["ref"])
+	}
+	if _, ok := where["ref"].(RefWhere); !ok {
+		t.Fatalf("input where changed, got %T", where["ref"])
+	}
+	sub := where["$or"].([]interface{})[0].(map[string]interface{})
+	if _, ok := sub["ref2"].(RefWhere); !ok {
+		t.Fatalf("input sub where changed, got %T", sub["ref2"])
+	}
+}
+
+func TestORMScopes(t *testing.T) {
+	ref := NewReference()
+	ref.AddTableDef("test3", tb3{})
+	ref.BuildRefs()
+
+	notDeleted := func(orm *ORM) *ORM {
+		return orm.Where("deleted", false)
+	}
+	ref.AddDefaultScope("test3", notDeleted)
+
+	tenant := func(id string) Scope {
+		return func(orm *ORM) *ORM {
+			return orm.Where("tenant_id", id)
+		}
+	}
+
+	orm := &ORM{ctx: context.Background(), refConf: ref, keepQuery: true, tableName: "test3", Q: newMongoOrmQ()}
+	orm.Scopes(tenant("t1")).Where("txt", "1")
+
+	cond := orm.Cond().Cond()
+	if len(cond) != 3 {
+		t.Fatalf("default scope not applied: %v", cond)
+	}
+	if _, ok := orm.Q.Where["deleted"]; ok {
+		t.Fatalf("default scope must not change orm query: %v", orm.Q.Where)
+	}
+
+	cond = orm.Unscoped().Cond().Cond()
+	if _, ok := cond["deleted"]; ok || len(cond) != 2 {
+		t.Fatalf("unscoped query error: %v", cond)
+	}
+
+	// 同名的字段条件与默认作用域同时生效，只能通过 Unscoped 忽略
+	orm = &ORM{ctx: context.Background(), refConf: ref, keepQuery: true, tableName: "test3", Q: newMongoOrmQ()}
+	cond = orm.Where("deleted", true).Cond().Cond()
+	if and, ok := cond["$and"].([]map[string]interface{}); !ok || len(and) != 1 || cond["deleted"] == nil {
+		t.Fatalf("same key must use $and: %v", cond)
+	}
+	cond = orm.Clone().Unscoped().Where("deleted", true).Cond().Cond()
+	if _, ok := cond["$and"]; ok || cond["deleted"].(map[string]interface{})["$eq"] != true {
+		t.Fatalf("unscoped user condition error: %v", cond)
+	}
+
+	// 租户作用域不能被同名条件覆盖
+	where := mergeScopeWhere(Where{"tenant_id": "t2"}, Where{"tenant_id": "t1"})
+	if _, ok := where["tenant_id"]; ok || len(andList(where["$and"])) != 2 {
+		t.Fatalf("tenant scope must not be replaced: %v", where)
+	}
+	if q := MixQ(where).JSON(); !strings.Contains(q, "t1") || !strings.Contains(q, "t2") {
+		t.Fatalf("both tenant conditions must apply: %s", q)
+	}
+
+	// $or 同名时两者同时生效
+	ref.AddDefaultScope("test3", func(orm *ORM) *ORM {
+		return orm.Where("$or", map[string]interface{}{"a": 1, "b": 1})
+	})
+	orm = &ORM{ctx: context.Background(), refConf: ref, keepQuery: true, tableName: "test3", Q: newMongoOrmQ()}
+	cond = orm.Where("$or", map[string]interface{}{"c": 1, "d": 1}).Cond().Cond()
+	if and, ok := cond["$and"].([]map[string]interface{}); !ok || len(and) != 2 {
+		t.Fatalf("conflict $or must use $and: %v", cond)
+	}
+}
+
+func TestORMQueryOptions(t *testing.T) {
+	base := (&ORM{Q: newMongoOrmQ()}).Immutable(true).Comment("list-user").MaxTime(time.Second)
+	o := base.HintKeys("name", "-age").Collation("zh", CollationStrengthSecondary).BatchSize(100)
+	if base.Q.opts.hint != nil || base.Q.opts.collation != nil {
+		t.Fatal("base orm must not be changed")
+	}
+
+	opts := NewFindOptions()
+	opts.BasicQueryOptions = o.Q.opts
+	mongoOpts := options.Find()
+	opts.applyFind(mongoOpts)
+	if *mongoOpts.Comment != "list-user" || *mongoOpts.MaxTime != time.Second || *mongoOpts.BatchSize != 100 {
+		t.Fatal("find options error")
+	}
+	if hint, ok := mongoOpts.Hint.(bson.D); !ok || len(hint) != 2 || hint[1].Value != -1 {
+		t.Fatalf("hint error: %v", mongoOpts.Hint)
+	}
+	if mongoOpts.Collation.Locale != "zh" || mongoOpts.Collation.Strength != CollationStrengthSecondary {
+		t.Fatal("collation error")
+	}
+
+	countOpts := options.Count()
+	opts.applyCount(countOpts)
+	if *countOpts.MaxTime != time.Second || countOpts.Hint == nil {
+		t.Fatal("count options error")
+	}
+	// 空字段名被忽略
+	opts = NewFindOptions()
+	opts.Sort([]string{"", "-", "-age"})
+	opts.Select([]string{"", "name"})
+	if len(opts.sort) != 1 || opts.sort[0].Key != "age" || len(opts.field) != 1 {
+		t.Fatalf("empty column error: %v, %v", opts.sort, opts.field)
+	}
+	if hint := base.HintKeys("", "name").Q.opts.hint.(bson.D); len(hint) != 1 {
+		t.Fatalf("empty hint key error: %v", hint)
+	}
+	if o := base.Order("", " ").Select(""); len(o.Q.Order) != 0 || len(o.Q.Select) != 0 {
+		t.Fatal("empty order or select must be ignored")
+	}
+}
+
+func TestORMMustFind(t *testing.T) {
+	base := &ORM{ctx: context.Background(), keepQuery: true, tableName: "test1", Q: newMongoOrmQ()}
+	base = base.Immutable(true)
+
+	strict := base.MustFind().Where("name", "a")
+	if base.Q.mustFind || !strict.Q.mustFind {
+		t.Fatalf("must find error: %v, %v", base.Q.mustFind, strict.Q.mustFind)
+	}
+	if !strict.Clone().Q.mustFind {
+		t.Fatal("clone lost must find")
+	}
+}
+
+type pkString struct {
+	ID string `bson:"_id"`
+}
+
+type pkInt struct {
+	ID int64 `bson:"_id"`
+}
+
+type pkUUID struct {
+	ID UUID `bson:"_id"`
+}
+
+func TestORMPrimaryKey(t *testing.T) {
+	ref := NewReference()
+	ref.AddTableDef("test3", tb3{})
+	ref.AddTableDef("pk_str", pkString{})
+	ref.AddTableDef("pk_int", pkInt{})
+	ref.AddTableDef("pk_uuid", pkUUID{})
+	ref.SetPrimaryKey("pk_str", KeyString)
+	ref.SetPrimaryKey("pk_int", KeyInt64)
+	ref.SetPrimaryKey("pk_uuid", KeyUUID)
+	ref.BuildRefs()
+
+	newORM := func(tbName string) *ORM {
+		return &ORM{ctx: context.Background(), refConf: ref, keepQuery: true, tableName: tbName, Q: newMongoOrmQ()}
+	}
+
+	idCond := func(tbName, key string, value interface{}, op string) interface{} {
+		cond := newORM(tbName).Where(key, value).Cond().Cond()
+		return cond["_id"].(map[string]interface{})[op]
+	}
+
+	if _, ok := idCond("test3", "_id", "62788a8e92961d9287c6b8d2", "$eq").(ObjectID); !ok {
+		t.Fatal("object id key must be converted")
+	}
+	if v := idCond("pk_str", "_id", "62788a8e92961d9287c6b8d2", "$eq"); v != "62788a8e92961d9287c6b8d2" {
+		t.Fatalf("string key error: %v", v)
+	}
+	if v := idCond("pk_str", "_id__startswith", "order-", "$regex"); v == nil {
+		t.Fatal("string key operator error")
+	}
+	in := idCond("pk_int", "_id__in", []string{"1", "2"}, "$in").([]interface{})
+	if in[0] != int64(1) || in[1] != int64(2) {
+		t.Fatalf("int64 key error: %v", in)
+	}
+	v := idCond("pk_uuid", "_id", "3f2a6c1e-8d4b-4c3a-9e2f-1a2b3c4d5e6f", "$eq")
+	if id, ok := v.(UUID); !ok || id.String() != "3f2a6c1e-8d4b-4c3a-9e2f-1a2b3c4d5e6f" {
+		t.Fatalf("uuid key error: %v", v)
+	}
+
+	m := map[string]interface{}{"name": "a"}
+	if err := KeyUUID.formatInsertID(m); err != nil || m["_id"].(UUID).IsZero() {
+		t.Fatalf("uuid key must be generated: %v, %v", m, err)
+	}
+	if err := KeyString.formatInsertID(map[string]interface{}{"name": "a"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("string key is required: %v", err)
+	}
+	m = map[string]interface{}{"_id": "62788a8e92961d9287c6b8d2"}
+	if err := KeyObjectID.formatInsertID(m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["_id"].(ObjectID); !ok {
+		t.Fatalf("object id key error: %T", m["_id"])
+	}
+	if err := KeyObjectID.formatInsertID(map[string]interface{}{"_id": "sku-1"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("object id key must be hex: %v", err)
+	}
+
+	doc := map[string]interface{}{"_id": "sku-1"}
+	bwm, err := NewBulkWriteModel().AddInsertOneModel(doc).withKeyType(KeyString)
+	if err != nil || bwm.models[0].(*mongo.InsertOneModel).Document.(map[string]interface{})["_id"] != "sku-1" {
+		t.Fatalf("string key insert error: %v", err)
+	}
+	if _, err = NewBulkWriteModel().AddInsertOneModel(doc).withKeyType(KeyObjectID); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("object id key insert must fail: %v", err)
+	}
+	bwm = NewBulkWriteModel().SetKeyType(KeyObjectID).AddInsertOneModel(map[string]interface{}{"_id": "62788a8e92961d9287c6b8d2"})
+	if _, ok := bwm.models[0].(*mongo.InsertOneModel).Document.(map[string]interface{})["_id"].(ObjectID); !ok {
+		t.Fatal("object id key insert must be converted")
+	}
+
+	if _, err = ToRefData(ref, &pkString{ID: "sku-1"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("string key cannot be referenced: %v", err)
+	}
+	if f, err := ToRefData(ref, &tb3{ID: "62788a8e92961d9287c6b8d2"}); err != nil || f.ID.Hex() != "62788a8e92961d9287c6b8d2" {
+		t.Fatalf("object id ref error: %v", err)
+	}
+}
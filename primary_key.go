@@ -0,0 +1,219 @@
+// Package mongo
+package mongo
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// KeyType 表的主键（_id）类型
+type KeyType int
+
+const (
+	// KeyObjectID 默认，字符串按 hex 转换为 ObjectID，插入时缺少主键由driver生成
+	KeyObjectID KeyType = iota
+	// KeyString 字符串，原样保存
+	KeyString
+	// KeyUUID UUID（mongo.UUID），字符串支持带或不带 "-" 的格式，插入时缺少主键自动生成
+	KeyUUID
+	// KeyInt64 整数，整数与数字字符串转换为 int64
+	KeyInt64
+	// KeyDocument 复合主键，文档原样保存，如：map[string]interface{}{"uid": 1, "day": "2022-01-01"}
+	KeyDocument
+)
+
+// SetPrimaryKey 设置表的主键类型，未设置的表为 KeyObjectID
+// ORM 的查询条件、插入数据中的 _id 按主键类型转换
+func (r *Reference) SetPrimaryKey(tbName string, kt KeyType) {
+	if _, ok := r.tableDef[tbName]; !ok {
+		panic(fmt.Sprintf("collection [%s] is not defined", tbName))
+	}
+	if kt < KeyObjectID || kt > KeyDocument {
+		panic(fmt.Sprintf("collection [%s] primary key type [%d] is invalid", tbName, kt))
+	}
+	if kt != KeyObjectID && r.referenced(tbName) {
+		panic(fmt.Sprintf("collection [%s] is referenced by foreign keys, foreign keys only support ObjectID", tbName))
+	}
+	r.primaryKeys[tbName] = kt
+}
+
+// referenced 表是否被外键引用，BuildRefs 之前外键的目标为结构体全名
+func (r *Reference) referenced(tbName string) bool {
+	tp := r.tableDef[tbName]
+	structFullName := fmt.Sprintf("%s.%s", tp.PkgPath(), tp.Name())
+	for _, refMap := range r.tableRef {
+		for _, ref := range refMap {
+			if ref.To == tbName || ref.To == structFullName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Reference) getPrimaryKey(tbName string) KeyType {
+	if r == nil {
+		return KeyObjectID
+	}
+	return r.primaryKeys[tbName]
+}
+
+// keyValue 已按主键类型转换的 _id 条件值，Q 中不再转换为 ObjectID
+type keyValue struct {
+	value interface{}
+}
+
+// idOperators 值为主键的查询操作符，其他操作符（如：exists、startswith）的值不转换
+var idOperators = map[string]bool{
+	"eq": true, "ne": true, "lt": true, "lte": true, "gt": true, "gte": true,
+	"in": true, "nin": true, "all": true, "between": true, "range": true,
+}
+
+// formatKeyCond 格式化 _id 查询条件，KeyObjectID 由 Q 转换
+func (kt KeyType) formatKeyCond(key string, value interface{}) interface{} {
+	if kt == KeyObjectID {
+		return value
+	}
+	if _, ok := value.(keyValue); ok {
+		return value
+	}
+
+	keys := strings.Split(key, "__")
+	if len(keys) == 1 || idOperators[keys[1]] {
+		return keyValue{value: kt.format(value)}
+	}
+	return keyValue{value: value}
+}
+
+// format 按主键类型转换值，数组逐个转换
+func (kt KeyType) format(value interface{}) interface{} {
+	switch kt {
+	case KeyString, KeyUUID, KeyInt64:
+	default:
+		return value
+	}
+
+	switch value.(type) {
+	case nil, []byte, [16]byte, UUID:
+		return kt.formatOne(value)
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		arr := make([]interface{}, rv.Len())
+		for i := range arr {
+			arr[i] = kt.formatOne(rv.Index(i).Interface())
+		}
+		return arr
+	}
+	return kt.formatOne(value)
+}
+
+func (kt KeyType) formatOne(value interface{}) interface{} {
+	switch kt {
+	case KeyString:
+		if b, ok := value.([]byte); ok {
+			return string(b)
+		}
+	case KeyUUID:
+		switch v := value.(type) {
+		case string:
+			return TryString2UUID(v)
+		case []byte:
+			var u UUID
+			if len(v) != len(u) {
+				panic(fmt.Sprintf("uuid length must be 16, got %d", len(v)))
+			}
+			copy(u[:], v)
+			return u
+		case [16]byte:
+			return UUID(v)
+		case primitive.Binary:
+			if u, ok := binaryUUID(v); ok {
+				return u
+			}
+		}
+	case KeyInt64:
+		switch v := value.(type) {
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				panic(err)
+			}
+			return i
+		case int64:
+			return v
+		}
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+			return rv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int64(rv.Uint())
+		}
+	}
+	return value
+}
+
+// formatInsertID 插入前按主键类型处理 _id
+// KeyObjectID：空值删除，由driver生成，非 hex 字符串返回错误；KeyUUID：缺少时生成；其他类型缺少时返回错误
+func (kt KeyType) formatInsertID(m map[string]interface{}) error {
+	id, ok := m["_id"]
+	if kt == KeyObjectID {
+		if !ok {
+			return nil
+		}
+		switch id := id.(type) {
+		case string:
+			if id == "" {
+				delete(m, "_id")
+				return nil
+			}
+			objID, err := String2ObjectID(id)
+			if err != nil {
+				return fmt.Errorf("%w: _id [%s] is not an ObjectID hex, set the primary key type of the table", ErrInvalidQuery, id)
+			}
+			m["_id"] = objID
+		case ObjectID:
+			if id.IsZero() {
+				delete(m, "_id")
+			}
+		case [12]byte:
+			if ObjectID(id).IsZero() {
+				delete(m, "_id")
+			}
+		}
+		return nil
+	}
+
+	if u, isUUID := id.(UUID); isUUID && u.IsZero() {
+		ok = false
+	}
+	if !ok || id == nil || id == "" {
+		if kt == KeyUUID {
+			m["_id"] = NewUUID()
+			return nil
+		}
+		return fmt.Errorf("%w: primary key _id is required", ErrInvalidQuery)
+	}
+	if kt != KeyDocument {
+		m["_id"] = kt.formatOne(id)
+	}
+	return nil
+}
+
+// insertedID 插入返回的主键，ObjectID 转换为 hex 字符串，Binary subtype 4 转换为 UUID，其他类型原样返回
+func insertedID(id interface{}) interface{} {
+	switch v := id.(type) {
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.Binary:
+		if u, ok := binaryUUID(v); ok {
+			return u
+		}
+	}
+	return id
+}
@@ -29,6 +29,25 @@ type queryNode struct {
 // exists : "key__exists": true
 // mod : "key__mod": [10, 1], 基数，余数
 // elemMatch : "key__match": MongoQuery
+// type : "key__type": "string" or 2 or []interface{}{"int", "long"}, bson 类型别名或编号
+// bits :
+//
+//	"key__bits_all_set": 5 or []byte or []int{0, 2} 位掩码或位置
+//	"key__bits_any_set": 5
+//	"key__bits_all_clear": 5
+//	"key__bits_any_clear": 5
+//
+// regex :
+//
+//	"key__regex": "^a.*" or primitive.Regex{Pattern: "^a", Options: "i"} or {"$regex": "^a", "$options": "im"}
+//	"key__iregex": "^a.*" 忽略大小写
+//
+// between : "key__between": [1, 10] or "key__range": [1, 10], 包含边界，nil 表示不限制
+// isnull : "key__isnull": true, true 为 null 或不存在，false 为存在且不为 null
+// in_range_dates : "key__in_range_dates": ["2022-01-01", "2022-01-31"] or []time.Time, 包含结束日期当天
+// text : "__text": "coffee" or {"$search": "coffee", "$language": "en"}, 全文检索，没有字段名
+// expr : "__expr": {"$gt": ["$spent", "$budget"]}, 没有字段名
+// jsonSchema : "__json_schema": {"required": ["name"]}, 没有字段名
 // like :
 //
 //	"key__istartswith": "123"
@@ -83,6 +102,11 @@ func NotQ(key string, value interface{}) *Query {
 	if len(rawQ.nodes) <= 0 {
 		panic(fmt.Sprintf("MongoNotQ key:%s, value:%v", key, value))
 	}
+	for _, node := range rawQ.nodes {
+		if topOperators[node.Key] {
+			panic(fmt.Sprintf("%s not supported '~'", node.Key))
+		}
+	}
 
 	q := NewQuery()
 	if len(rawQ.nodes) == 1 {
@@ -109,6 +133,8 @@ func NotQ(key string, value interface{}) *Query {
 
 func idFormat(value interface{}) interface{} {
 	switch val := value.(type) {
+	case keyValue:
+		value = val.value
 	case string:
 		value = TryString2ObjectID(val)
 	case []string:
@@ -396,6 +422,10 @@ func geoCondition(key string, keys []string, value interface{}) (string, interfa
 	if has {
 		return key, value
 	}
+	key, value, has = operatorCondition(key, keys, value)
+	if has {
+		return key, value
+	}
 
 	switch keys[1] {
 	case "istartswith":
@@ -455,7 +485,12 @@ func Q(key string, value interface{}) *Query {
 	if strings.Contains(key, "__") {
 		keys := strings.Split(key, "__")
 		if keys[0] == "_id" || util.EndWith(keys[0], ".$id", false) {
-			value = idFormat(value)
+			// 只有值为主键的操作符转换为 ObjectID，如：type、exists 的值原样保留
+			if kv, ok := value.(keyValue); ok {
+				value = kv.value
+			} else if idOperators[keys[1]] {
+				value = idFormat(value)
+			}
 		}
 
 		key, value = geoCondition(key, keys, value)
@@ -663,11 +698,11 @@ func (q *Query) innerNodeFilter(node queryNode) (k string, v interface{}) {
 		return key, map[string]interface{}{
 			"$not": val,
 		}
-	} else if node.Key == "$and" {
+	} else if node.Key == "$and" || topOperators[node.Key] {
 		return node.Key, node.Value
 	} else if strings.Contains(node.Key, "__") {
 		keys := strings.Split(node.Key, "__")
-		if keys[1] == "regex" {
+		if keys[1] == "regex" || keys[1] == opRaw {
 			return keys[0], node.Value
 		}
 		return keys[0], map[string]interface{}{
@@ -683,7 +718,8 @@ func (q *Query) innerFilter(nodes []queryNode) map[string]interface{} {
 	filter := map[string]interface{}{}
 	for _, node := range nodes {
 		k, v := q.innerNodeFilter(node)
-		if k == "$or" || k == "$nor" {
+		_, exist := filter[k]
+		if k == "$or" || k == "$nor" || (topOperators[k] && exist) || conflictOp(filter[k], v) {
 			if andQ, ok := filter["$and"]; ok {
 				andQ = append(andQ.([]map[string]interface{}), map[string]interface{}{
 					k: v,
@@ -716,6 +752,24 @@ func (q *Query) innerFilter(nodes []queryNode) map[string]interface{} {
 	return filter
 }
 
+// conflictOp 同一字段的相同操作符（$not 除外）不能合并，合并会覆盖之前的条件，如：$and 中的 {"a": 1} 与 {"a": 2}
+func conflictOp(old, v interface{}) bool {
+	oldM, ok := old.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for c := range m {
+		if _, exist := oldM[c]; exist && c != "$not" {
+			return true
+		}
+	}
+	return false
+}
+
 func (q *Query) Cond() map[string]interface{} {
 	if len(q.rawCond) > 0 {
 		return q.rawCond
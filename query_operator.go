@@ -0,0 +1,299 @@
+// Package mongo
+package mongo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// opRaw 值为字段的完整条件，如：{"$gte": 1, "$lte": 2}
+const opRaw = "$raw"
+
+// topOperators 顶层操作符，不属于某个字段，如："__text": "coffee"
+var topOperators = map[string]bool{
+	"$text":       true,
+	"$expr":       true,
+	"$jsonSchema": true,
+}
+
+// bsonTypeAliases $type 支持的类型别名
+var bsonTypeAliases = map[string]bool{
+	"double": true, "string": true, "object": true, "array": true, "binData": true, "undefined": true,
+	"objectId": true, "bool": true, "date": true, "null": true, "regex": true, "dbPointer": true,
+	"javascript": true, "symbol": true, "int": true, "timestamp": true, "long": true, "decimal": true,
+	"minKey": true, "maxKey": true, "number": true,
+}
+
+// dateLayouts in_range_dates 支持的日期字符串格式，按本地时区解析
+var dateLayouts = []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339}
+
+// operatorCondition 类型、位运算、正则、范围、顶层操作符
+func operatorCondition(key string, keys []string, value interface{}) (string, interface{}, bool) {
+	op := keys[1]
+	switch op {
+	case "type":
+		checkTypeValue(value)
+		return key, value, true
+	case "bits_all_set", "bitsAllSet":
+		checkBitsValue(op, value)
+		return keys[0] + "__bitsAllSet", value, true
+	case "bits_any_set", "bitsAnySet":
+		checkBitsValue(op, value)
+		return keys[0] + "__bitsAnySet", value, true
+	case "bits_all_clear", "bitsAllClear":
+		checkBitsValue(op, value)
+		return keys[0] + "__bitsAllClear", value, true
+	case "bits_any_clear", "bitsAnyClear":
+		checkBitsValue(op, value)
+		return keys[0] + "__bitsAnyClear", value, true
+	case "regex":
+		return keys[0] + "__" + opRaw, regexValue(value, ""), true
+	case "iregex":
+		return keys[0] + "__" + opRaw, regexValue(value, "i"), true
+	case "between", "range":
+		return keys[0] + "__" + opRaw, betweenValue(op, value), true
+	case "isnull":
+		b, ok := value.(bool)
+		if !ok {
+			panic("type of isnull's value must be bool")
+		}
+		if b {
+			return keys[0] + "__eq", nil, true
+		}
+		return keys[0] + "__ne", nil, true
+	case "in_range_dates":
+		start, end := dateRangeValue(value)
+		return keys[0] + "__" + opRaw, map[string]interface{}{"$gte": start, "$lt": end}, true
+	case "text":
+		checkTopOperator(op, keys)
+		return "$text", textValue(value), true
+	case "expr":
+		checkTopOperator(op, keys)
+		return "$expr", documentValue(op, value), true
+	case "json_schema", "jsonSchema":
+		checkTopOperator(op, keys)
+		return "$jsonSchema", documentValue(op, value), true
+	}
+	return key, value, false
+}
+
+func checkTopOperator(op string, keys []string) {
+	if keys[0] != "" {
+		panic(fmt.Sprintf("operator[%s] can not have field, use \"__%s\"", op, op))
+	}
+}
+
+// checkTypeValue 类型别名、类型编号或它们的数组
+func checkTypeValue(value interface{}) {
+	check := func(v interface{}) bool {
+		if s, ok := v.(string); ok {
+			return bsonTypeAliases[s]
+		}
+		switch reflect.ValueOf(v).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return true
+		}
+		return false
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		if rv.Len() <= 0 {
+			panic("type of type's value can not be empty")
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if !check(rv.Index(i).Interface()) {
+				panic(fmt.Sprintf("type's value [%v] is not a bson type", rv.Index(i).Interface()))
+			}
+		}
+		return
+	}
+	if !check(value) {
+		panic(fmt.Sprintf("type's value [%v] is not a bson type", value))
+	}
+}
+
+// checkBitsValue 位掩码（非负整数）、[]byte 或位置数组
+func checkBitsValue(op string, value interface{}) {
+	isUint := func(v interface{}) bool {
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int() >= 0
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return true
+		}
+		return false
+	}
+
+	switch value.(type) {
+	case []byte, primitive.Binary:
+		return
+	}
+	if isUint(value) {
+		return
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		for i := 0; i < rv.Len(); i++ {
+			if !isUint(rv.Index(i).Interface()) {
+				panic(fmt.Sprintf("%s's bit position [%v] must be non-negative integer", op, rv.Index(i).Interface()))
+			}
+		}
+		return
+	}
+	panic(fmt.Sprintf("type of %s's value must be non-negative integer, []byte or []int", op))
+}
+
+// regexValue 正则表达式：string、primitive.Regex 或 {"$regex": "", "$options": ""}（map、bson.M、bson.D）
+func regexValue(value interface{}, options string) map[string]interface{} {
+	switch v := value.(type) {
+	case primitive.M:
+		value = map[string]interface{}(v)
+	case primitive.D:
+		m := make(map[string]interface{}, len(v))
+		for _, e := range v {
+			m[e.Key] = e.Value
+		}
+		value = m
+	}
+
+	var pattern string
+	switch v := value.(type) {
+	case string:
+		pattern = v
+	case primitive.Regex:
+		pattern, options = v.Pattern, options+v.Options
+	case map[string]interface{}:
+		p, ok := v["$regex"].(string)
+		if !ok {
+			panic("regex's value must contain $regex string")
+		}
+		pattern = p
+		if o, ok := v["$options"]; ok {
+			s, ok := o.(string)
+			if !ok {
+				panic("regex's $options must be string")
+			}
+			options += s
+		}
+	default:
+		panic("type of regex's value must be string, primitive.Regex, map[string]interface{}, bson.M or bson.D")
+	}
+
+	for _, c := range options {
+		if !strings.ContainsRune("imxsu", c) {
+			panic(fmt.Sprintf("regex's option [%c] is invalid, must be in 'imxsu'", c))
+		}
+	}
+	cond := map[string]interface{}{"$regex": pattern}
+	if options != "" {
+		cond["$options"] = options
+	}
+	return cond
+}
+
+// betweenValue [min, max]，包含边界，nil 表示不限制
+func betweenValue(op string, value interface{}) map[string]interface{} {
+	rv := reflect.ValueOf(value)
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Len() != 2 {
+		panic(fmt.Sprintf("type of %s's value must be array and length need 2", op))
+	}
+
+	cond := map[string]interface{}{}
+	if v := rv.Index(0).Interface(); v != nil {
+		cond["$gte"] = v
+	}
+	if v := rv.Index(1).Interface(); v != nil {
+		cond["$lte"] = v
+	}
+	if len(cond) <= 0 {
+		panic(fmt.Sprintf("%s's min and max can not both be nil", op))
+	}
+	return cond
+}
+
+// dateRangeValue [开始日期, 结束日期]，包含结束日期当天，返回 [开始日期 0 点, 结束日期次日 0 点)
+func dateRangeValue(value interface{}) (time.Time, time.Time) {
+	rv := reflect.ValueOf(value)
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Len() != 2 {
+		panic("type of in_range_dates's value must be array and length need 2")
+	}
+
+	day := func(v interface{}) time.Time {
+		var t time.Time
+		switch v := v.(type) {
+		case time.Time:
+			t = v
+		case string:
+			var err error
+			for _, layout := range dateLayouts {
+				if t, err = time.ParseInLocation(layout, v, time.Local); err == nil {
+					break
+				}
+			}
+			if err != nil {
+				panic(fmt.Sprintf("in_range_dates's date [%s] is invalid", v))
+			}
+		default:
+			panic("type of in_range_dates's date must be time.Time or string")
+		}
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+
+	start, end := day(rv.Index(0).Interface()), day(rv.Index(1).Interface())
+	if end.Before(start) {
+		panic("in_range_dates's end date must be gte start date")
+	}
+	return start, end.AddDate(0, 0, 1)
+}
+
+// textValue 全文检索：检索词或 {"$search": "", "$language": "", "$caseSensitive": false, "$diacriticSensitive": false}
+func textValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			panic("text's search can not be empty")
+		}
+		return map[string]interface{}{"$search": v}
+	case map[string]interface{}:
+		if s, ok := v["$search"].(string); !ok || s == "" {
+			panic("text's value must contain $search string")
+		}
+		for k := range v {
+			switch k {
+			case "$search", "$language", "$caseSensitive", "$diacriticSensitive":
+			default:
+				panic(fmt.Sprintf("text's option [%s] is invalid", k))
+			}
+		}
+		return v
+	}
+	panic("type of text's value must be string or map[string]interface{}")
+}
+
+// documentValue $expr、$jsonSchema 的值，不能为空文档
+func documentValue(op string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) > 0 {
+			return v
+		}
+	case primitive.M:
+		if len(v) > 0 {
+			return v
+		}
+	case primitive.D:
+		if len(v) > 0 {
+			return v
+		}
+	default:
+		panic(fmt.Sprintf("type of %s's value must be map[string]interface{}, bson.M or bson.D", op))
+	}
+	panic(fmt.Sprintf("%s's value can not be empty", op))
+}
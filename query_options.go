@@ -2,7 +2,11 @@
 package mongo
 
 import (
+	"strings"
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // 所有索引类型
@@ -30,24 +34,43 @@ const (
 	UpdatePush  = updateType("$push")
 	UpdatePull  = updateType("$pull")
 	UpdatePop   = updateType("$pop")
+	// UpdateSetOnInsert 只在 upsert 插入文档时设置
+	UpdateSetOnInsert = updateType("$setOnInsert")
 )
 
+// UpdateOps 多个更新操作，如：{UpdateSet: {...}, UpdateUnset: {...}}
+type UpdateOps map[updateType]map[string]interface{}
+
+// document 更新文档，忽略空的操作
+func (ops UpdateOps) document() bson.M {
+	doc := bson.M{}
+	for op, fields := range ops {
+		if len(fields) > 0 {
+			doc[op.String()] = fields
+		}
+	}
+	return doc
+}
+
 type BasicFindOptions struct {
 	field      bson.D
 	sort       bson.D
 	projection map[string]interface{}
+	// errorOnMissing 未找到文档时返回 ErrNotFound
+	errorOnMissing bool
 }
 
-// Select 设置需要展示或隐藏的字段
+// Select 设置需要展示或隐藏的字段，忽略空字段名
 // 如：["key1", "-key2"]，显示key1，隐藏key2
 func (op *BasicFindOptions) Select(cols []string) *BasicFindOptions {
 	for _, col := range cols {
+		col, desc, ok := parseColumn(col)
+		if !ok {
+			continue
+		}
 		v := 1
-		if col[0] == '-' {
+		if desc {
 			v = 0
-			col = col[1:]
-		} else if col[0] == '+' {
-			col = col[1:]
 		}
 		op.field = append(op.field, bson.E{Key: col, Value: v})
 	}
@@ -64,23 +87,228 @@ func (op *BasicFindOptions) Projection(projectionMap map[string]interface{}) *Ba
 	return op
 }
 
-// Sort 设置排序字段
+// Sort 设置排序字段，忽略空字段名
 // 如：["key1", "-key2"]，key1 升序，key2 降序
 func (op *BasicFindOptions) Sort(cols []string) *BasicFindOptions {
 	for _, col := range cols {
+		col, desc, ok := parseColumn(col)
+		if !ok {
+			continue
+		}
 		v := 1
-		if col[0] == '-' {
+		if desc {
 			v = -1
-			col = col[1:]
-		} else if col[0] == '+' {
-			col = col[1:]
 		}
 		op.sort = append(op.sort, bson.E{Key: col, Value: v})
 	}
 	return op
 }
 
+// parseColumn 解析 "key"、"+key"、"-key"，字段名为空时 ok 为 false
+func parseColumn(col string) (key string, desc bool, ok bool) {
+	col = strings.TrimSpace(col)
+	if col == "" {
+		return "", false, false
+	}
+	switch col[0] {
+	case '-':
+		desc = true
+		col = col[1:]
+	case '+':
+		col = col[1:]
+	}
+	return col, desc, col != ""
+}
+
+// ErrorOnMissing 未找到文档时返回 ErrNotFound，默认返回 nil 且不修改结果
+// 只对 FindOne、FindOneAnd* 有效
+func (op *BasicFindOptions) ErrorOnMissing() *BasicFindOptions {
+	op.errorOnMissing = true
+	return op
+}
+
+// Collation 比较强度
+const (
+	// CollationStrengthPrimary 只比较基础字符，忽略大小写和变音符号
+	CollationStrengthPrimary = 1
+	// CollationStrengthSecondary 比较基础字符和变音符号，忽略大小写
+	CollationStrengthSecondary = 2
+	// CollationStrengthTertiary 比较基础字符、变音符号和大小写，默认值
+	CollationStrengthTertiary = 3
+)
+
+// BasicQueryOptions 服务端查询选项，操作不支持的选项会被忽略
+// Find：全部；FindOne：MaxTime Hint Collation Comment；Count：MaxTime Hint Collation；
+// Distinct：MaxTime Collation；Aggregate：除 NoCursorTimeout 外全部
+type BasicQueryOptions struct {
+	maxTime         *time.Duration
+	hint            interface{}
+	collation       *options.Collation
+	comment         *string
+	allowDiskUse    *bool
+	batchSize       *int32
+	noCursorTimeout *bool
+}
+
+// MaxTime 服务端最大执行时间
+func (op *BasicQueryOptions) MaxTime(d time.Duration) *BasicQueryOptions {
+	if d <= 0 {
+		panic("max time must be gt 0")
+	}
+	op.maxTime = &d
+	return op
+}
+
+// Hint 按索引名称指定索引
+func (op *BasicQueryOptions) Hint(indexName string) *BasicQueryOptions {
+	if indexName == "" {
+		panic("hint index name must not be empty")
+	}
+	op.hint = indexName
+	return op
+}
+
+// HintKeys 按索引字段指定索引，如：["key1", "-key2"]，key1 升序，key2 降序，忽略空字段名
+func (op *BasicQueryOptions) HintKeys(cols ...string) *BasicQueryOptions {
+	keys := bson.D{}
+	for _, col := range cols {
+		col, desc, ok := parseColumn(col)
+		if !ok {
+			continue
+		}
+		v := 1
+		if desc {
+			v = -1
+		}
+		keys = append(keys, bson.E{Key: col, Value: v})
+	}
+	if len(keys) <= 0 {
+		panic("hint keys must not be empty")
+	}
+	op.hint = keys
+	return op
+}
+
+// Collation 设置排序规则，如：Collation("zh", CollationStrengthSecondary) 中文排序并忽略大小写
+func (op *BasicQueryOptions) Collation(locale string, strength int) *BasicQueryOptions {
+	if locale == "" {
+		panic("collation locale must not be empty")
+	}
+	op.collation = &options.Collation{Locale: locale, Strength: strength}
+	return op
+}
+
+// Comment 查询注释，可在慢查询日志、currentOp 中查看
+func (op *BasicQueryOptions) Comment(comment string) *BasicQueryOptions {
+	op.comment = &comment
+	return op
+}
+
+// AllowDiskUse 允许使用磁盘临时文件排序
+func (op *BasicQueryOptions) AllowDiskUse(b bool) *BasicQueryOptions {
+	op.allowDiskUse = &b
+	return op
+}
+
+// BatchSize 每批返回的文档数
+func (op *BasicQueryOptions) BatchSize(n int32) *BasicQueryOptions {
+	if n < 0 {
+		panic("batch size must be gte 0")
+	}
+	op.batchSize = &n
+	return op
+}
+
+// NoCursorTimeout 游标不超时，需要确保游标被关闭
+func (op *BasicQueryOptions) NoCursorTimeout(b bool) *BasicQueryOptions {
+	op.noCursorTimeout = &b
+	return op
+}
+
+func (op *BasicQueryOptions) applyFind(opts *options.FindOptions) {
+	if op.maxTime != nil {
+		opts.SetMaxTime(*op.maxTime)
+	}
+	if op.hint != nil {
+		opts.SetHint(op.hint)
+	}
+	if op.collation != nil {
+		opts.SetCollation(op.collation)
+	}
+	if op.comment != nil {
+		opts.SetComment(*op.comment)
+	}
+	if op.allowDiskUse != nil {
+		opts.SetAllowDiskUse(*op.allowDiskUse)
+	}
+	if op.batchSize != nil {
+		opts.SetBatchSize(*op.batchSize)
+	}
+	if op.noCursorTimeout != nil {
+		opts.SetNoCursorTimeout(*op.noCursorTimeout)
+	}
+}
+
+func (op *BasicQueryOptions) applyFindOne(opts *options.FindOneOptions) {
+	if op.maxTime != nil {
+		opts.SetMaxTime(*op.maxTime)
+	}
+	if op.hint != nil {
+		opts.SetHint(op.hint)
+	}
+	if op.collation != nil {
+		opts.SetCollation(op.collation)
+	}
+	if op.comment != nil {
+		opts.SetComment(*op.comment)
+	}
+}
+
+func (op *BasicQueryOptions) applyCount(opts *options.CountOptions) {
+	if op.maxTime != nil {
+		opts.SetMaxTime(*op.maxTime)
+	}
+	if op.hint != nil {
+		opts.SetHint(op.hint)
+	}
+	if op.collation != nil {
+		opts.SetCollation(op.collation)
+	}
+}
+
+func (op *BasicQueryOptions) applyDistinct(opts *options.DistinctOptions) {
+	if op.maxTime != nil {
+		opts.SetMaxTime(*op.maxTime)
+	}
+	if op.collation != nil {
+		opts.SetCollation(op.collation)
+	}
+}
+
+func (op *BasicQueryOptions) applyAggregate(opts *options.AggregateOptions) {
+	if op.maxTime != nil {
+		opts.SetMaxTime(*op.maxTime)
+	}
+	if op.hint != nil {
+		opts.SetHint(op.hint)
+	}
+	if op.collation != nil {
+		opts.SetCollation(op.collation)
+	}
+	if op.comment != nil {
+		opts.SetComment(*op.comment)
+	}
+	if op.allowDiskUse != nil {
+		opts.SetAllowDiskUse(*op.allowDiskUse)
+	}
+	if op.batchSize != nil {
+		opts.SetBatchSize(*op.batchSize)
+	}
+}
+
 type BasicUpdateOptions struct {
+	BasicWriteOptions
+
 	upsert *bool
 }
 
@@ -99,6 +327,7 @@ type Replace struct {
 
 type FindOneAndDelete struct {
 	BasicFindOptions
+	BasicWriteOptions
 }
 
 type FindOneAndReplace struct {
@@ -113,6 +342,8 @@ type FindOneAndUpdate struct {
 
 type FindOneOptions struct {
 	BasicFindOptions
+	BasicReadOptions
+	BasicQueryOptions
 
 	skip *int64
 }
@@ -125,6 +356,9 @@ type FindOptions struct {
 }
 
 type CountOptions struct {
+	BasicReadOptions
+	BasicQueryOptions
+
 	skip  *int64
 	limit *int64
 }
@@ -222,6 +456,59 @@ func (op *FindOptions) Page(no int64, size int64) *FindOptions {
 	return op
 }
 
+// InsertOptions 插入选项
+type InsertOptions struct {
+	BasicWriteOptions
+}
+
+// NewInsertOptions 创建插入选项
+func NewInsertOptions() *InsertOptions {
+	op := new(InsertOptions)
+	return op
+}
+
+// DeleteOptions 删除选项
+type DeleteOptions struct {
+	BasicWriteOptions
+}
+
+// NewDeleteOptions 创建删除选项
+func NewDeleteOptions() *DeleteOptions {
+	op := new(DeleteOptions)
+	return op
+}
+
+// AggregateOptions 聚合选项，$out、$merge 阶段使用写关注
+type AggregateOptions struct {
+	BasicReadOptions
+	BasicWriteOptions
+	BasicQueryOptions
+}
+
+// NewAggregateOptions 创建聚合选项
+func NewAggregateOptions() *AggregateOptions {
+	op := new(AggregateOptions)
+	return op
+}
+
+// MaxTime 服务端最大执行时间
+func (op *AggregateOptions) MaxTime(d time.Duration) *AggregateOptions {
+	op.BasicQueryOptions.MaxTime(d)
+	return op
+}
+
+// DistinctOptions 去重查询选项
+type DistinctOptions struct {
+	BasicReadOptions
+	BasicQueryOptions
+}
+
+// NewDistinctOptions 创建去重查询选项
+func NewDistinctOptions() *DistinctOptions {
+	op := new(DistinctOptions)
+	return op
+}
+
 // Index 索引数据结构
 type Index struct {
 	Key   string
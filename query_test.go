@@ -0,0 +1,92 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestQueryOperators(t *testing.T) {
+	cond := MixQ(map[string]interface{}{
+		"kind__type":              []interface{}{"int", "long"},
+		"_id__type":               "objectId",
+		"flags__bits_all_set":     []int{0, 2},
+		"name__regex":             primitive.Regex{Pattern: "^a", Options: "m"},
+		"code__iregex":            "^x",
+		"age__between":            []interface{}{18, nil},
+		"score__range":            [2]int{1, 10},
+		"deleted_at__isnull":      true,
+		"created__in_range_dates": []string{"2022-01-01", "2022-01-31"},
+		"__text":                  "coffee",
+		"__expr":                  map[string]interface{}{"$gt": []interface{}{"$spent", "$budget"}},
+	}).Cond()
+
+	want := map[string]interface{}{
+		"kind":       map[string]interface{}{"$type": []interface{}{"int", "long"}},
+		"_id":        map[string]interface{}{"$type": "objectId"},
+		"flags":      map[string]interface{}{"$bitsAllSet": []int{0, 2}},
+		"name":       map[string]interface{}{"$regex": "^a", "$options": "m"},
+		"code":       map[string]interface{}{"$regex": "^x", "$options": "i"},
+		"age":        map[string]interface{}{"$gte": 18},
+		"score":      map[string]interface{}{"$gte": 1, "$lte": 10},
+		"deleted_at": map[string]interface{}{"$eq": nil},
+		"created": map[string]interface{}{
+			"$gte": time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local),
+			"$lt":  time.Date(2022, 2, 1, 0, 0, 0, 0, time.Local),
+		},
+		"$text": map[string]interface{}{"$search": "coffee"},
+		"$expr": map[string]interface{}{"$gt": []interface{}{"$spent", "$budget"}},
+	}
+	if !reflect.DeepEqual(cond, want) {
+		t.Fatalf("cond error: %v", cond)
+	}
+
+	// bson.M、bson.D 与 map 一致
+	cond = MixQ(map[string]interface{}{
+		"name__regex":  bson.M{"$regex": "^a", "$options": "m"},
+		"code__iregex": bson.D{{Key: "$regex", Value: "^x"}},
+	}).Cond()
+	if !reflect.DeepEqual(cond["name"], want["name"]) || !reflect.DeepEqual(cond["code"], want["code"]) {
+		t.Fatalf("bson regex error: %v", cond)
+	}
+
+	// 同一字段的多个条件合并
+	cond = MixQ(map[string]interface{}{"age__between": []int{1, 9}, "age__ne": 5}).Cond()
+	if !reflect.DeepEqual(cond["age"], map[string]interface{}{"$gte": 1, "$lte": 9, "$ne": 5}) {
+		t.Fatalf("merge error: %v", cond)
+	}
+	cond = MixQ(map[string]interface{}{"$and": []interface{}{Where{"age": 1}, Where{"age": 2}}}).Cond()
+	if and, ok := cond["$and"].([]map[string]interface{}); !ok || len(and) != 1 || cond["age"] == nil {
+		t.Fatalf("same operator must not be overwritten: %v", cond)
+	}
+	cond = NewAnd(Q("__expr", map[string]interface{}{"$eq": []interface{}{"$a", 1}}),
+		Q("__expr", map[string]interface{}{"$eq": []interface{}{"$b", 2}})).Cond()
+	if and, ok := cond["$and"].([]map[string]interface{}); !ok || len(and) != 1 || cond["$expr"] == nil {
+		t.Fatalf("duplicate top operator error: %v", cond)
+	}
+
+	for key, value := range map[string]interface{}{
+		"kind__type":          "integer",
+		"flags__bits_any_set": -1,
+		"name__regex":         primitive.Regex{Pattern: "^a", Options: "g"},
+		"age__between":        []interface{}{nil, nil},
+		"age__range":          []int{1},
+		"deleted_at__isnull":  "yes",
+		"day__in_range_dates": []string{"2022-02-01", "2022-01-01"},
+		"name__text":          "coffee",
+		"__json_schema":       map[string]interface{}{},
+		"~__text":             "coffee",
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%s must panic", key)
+				}
+			}()
+			MixQ(map[string]interface{}{key: value})
+		}()
+	}
+}
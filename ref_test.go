@@ -5,3 +5,48 @@ import "testing"
 func TestRefs(t *testing.T) {
 	simpleRef()
 }
+
+type refOmit struct {
+	ID  string        `bson:"_id,omitempty"`
+	Ref *Foreign[tb3] `bson:"ref,omitempty" ref:"def"`
+}
+
+func TestRefTags(t *testing.T) {
+	ref := NewReference()
+	ref.AddTableDef("test3", tb3{})
+	ref.AddTableDef("ref_omit", refOmit{})
+	ref.BuildRefs()
+
+	if ref.getRef("ref_omit", "ref") == nil {
+		t.Fatal("ref with omitempty must be defined")
+	}
+	f, err := ToRefData(ref, &refOmit{ID: "62788a8e92961d9287c6b8d2"})
+	if err != nil || f.ID.Hex() != "62788a8e92961d9287c6b8d2" {
+		t.Fatalf("_id with omitempty error: %v", err)
+	}
+}
+
+func TestRefPrimaryKey(t *testing.T) {
+	mustPanic := func(name string, fn func()) {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("%s must panic", name)
+			}
+		}()
+		fn()
+	}
+
+	ref := NewReference()
+	ref.AddTableDef("test3", tb3{})
+	ref.AddTableDef("ref_omit", refOmit{})
+	mustPanic("SetPrimaryKey before BuildRefs", func() { ref.SetPrimaryKey("test3", KeyString) })
+	ref.BuildRefs()
+	mustPanic("SetPrimaryKey after BuildRefs", func() { ref.SetPrimaryKey("test3", KeyUUID) })
+	ref.SetPrimaryKey("ref_omit", KeyString)
+
+	ref = NewReference()
+	ref.AddTableDef("test3", tb3{})
+	ref.primaryKeys["test3"] = KeyInt64
+	ref.AddTableDef("ref_omit", refOmit{})
+	mustPanic("BuildRefs", ref.BuildRefs)
+}
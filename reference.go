@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strings"
 
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -14,6 +15,7 @@ const foreignKeyErrStr = "foreign keys must use mongo.Foreign[ForeignCollectionS
 
 // refQ Mongo ref query
 type refQ struct {
+	Ctx   context.Context
 	From  string
 	Ref   *Reference
 	DB    *Database
@@ -48,8 +50,13 @@ func (q *refQ) getData(colName string) ([]interface{}, int) {
 	opt := NewFindOptions()
 	opt.Select([]string{"_id"})
 
+	ctx := q.Ctx
+	if ctx == nil {
+		ctx = q.DB.ctx
+	}
+
 	var idData []map[string]interface{}
-	err := collection.FindDocs(q.DB.ctx, q.Query, &idData, opt)
+	err := collection.FindDocs(ctx, q.Query, &idData, opt)
 	if err != nil {
 		panic(err)
 	}
@@ -65,6 +72,7 @@ type dataType interface{}
 // Foreign mongotool 外键
 // tag: `bson "test" json:"test" ref:"def"` ref values [def, all, match]
 // def: 有交集即可；all：所有的外键均存在；match：所有的外键均存在，并且与条件完全一致
+// 外键只能引用 ObjectID 主键（KeyObjectID）的表，引用其他主键类型的表时 BuildRefs、SetPrimaryKey panic
 type Foreign[T dataType] struct {
 	Ref string   `bson:"$ref" json:"ref"`
 	ID  ObjectID `bson:"$id" json:"id"`
@@ -76,12 +84,32 @@ type Foreign[T dataType] struct {
 type ForeignList[T dataType] []*Foreign[T]
 
 func (r *Foreign[T]) ToData(ctx context.Context, db *Database, data interface{}) error {
+	return r.toData(ctx, db, data, false)
+}
+
+// MustGetData 获取外键数据，外键为空或文档不存在时返回 ErrNotFound
+func (r *Foreign[T]) MustGetData(ctx context.Context, db *Database) (*T, error) {
+	var d T
+	err := r.toData(ctx, db, &d, true)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *Foreign[T]) toData(ctx context.Context, db *Database, data interface{}, errorOnMissing bool) error {
 	if r.Ref == "" || ObjectIDIsZero(r.ID) {
+		if errorOnMissing {
+			return fmt.Errorf("%w: foreign key is empty", ErrNotFound)
+		}
 		return nil
 	}
 	collection := db.Collection(r.Ref)
 
 	opt := NewFindOneOptions()
+	if errorOnMissing {
+		opt.ErrorOnMissing()
+	}
 	q := MixQ(map[string]interface{}{
 		"_id": r.ID,
 	})
@@ -92,6 +120,7 @@ func (r *Foreign[T]) ToData(ctx context.Context, db *Database, data interface{})
 	return nil
 }
 
+// GetData 获取外键数据，外键为空或文档不存在时返回零值，需要区分时使用 MustGetData
 func (r *Foreign[T]) GetData(ctx context.Context, db *Database) (*T, error) {
 	var d T
 	err := r.ToData(ctx, db, &d)
@@ -147,6 +176,8 @@ type Reference struct {
 	tableDef      map[string]reflect.Type
 	tableRef      map[string]map[string]*refType
 	structToTable map[string]string
+	defaultScopes map[string][]Scope
+	primaryKeys   map[string]KeyType
 }
 
 const (
@@ -166,6 +197,8 @@ func NewReference() *Reference {
 	ref.tableDef = map[string]reflect.Type{}
 	ref.tableRef = map[string]map[string]*refType{}
 	ref.structToTable = map[string]string{}
+	ref.defaultScopes = map[string][]Scope{}
+	ref.primaryKeys = map[string]KeyType{}
 	return ref
 }
 
@@ -192,12 +225,20 @@ func (r *Reference) AddTableDef(tbName string, def interface{}) {
 	r.tableDef[tbName] = tp
 
 	for i := 0; i < tp.NumField(); i++ {
-		colName := tp.Field(i).Tag.Get("bson")
-		if colName == "" || !tp.Field(i).IsExported() {
+		sf := tp.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tags, err := bsoncodec.DefaultStructTagParser.ParseStructTags(sf)
+		if err != nil {
+			panic(fmt.Sprintf("table [%s] field [%s] tag error: %v", tbName, sf.Name, err))
+		}
+		if tags.Skip || tags.Inline {
 			continue
 		}
+		colName := tags.Name
 
-		ref := tp.Field(i).Tag.Get("ref")
+		ref := sf.Tag.Get("ref")
 		if ref != "" {
 			t := mongoRefDefault
 			if ref == "all" {
@@ -206,7 +247,7 @@ func (r *Reference) AddTableDef(tbName string, def interface{}) {
 				t = mongoRefMatch
 			}
 
-			colType := tp.Field(i).Type
+			colType := sf.Type
 			if colType.Kind() == reflect.Ptr {
 				colType = colType.Elem()
 			}
@@ -249,6 +290,9 @@ func (r *Reference) BuildRefs() {
 			if ref.To == "" {
 				panic(errStr)
 			}
+			if kt := r.getPrimaryKey(ref.To); kt != KeyObjectID {
+				panic(fmt.Sprintf("table [%s] primary key type [%d] cannot be referenced, foreign keys only support ObjectID", ref.To, kt))
+			}
 		}
 	}
 }
@@ -290,26 +334,9 @@ func ToRefData[T dataType](r *Reference, data *T) (*Foreign[T], error) {
 		return nil, fmt.Errorf("struct[%s] is undefined", structFullName)
 	}
 
-	var objID ObjectID
-	var err error
-	for i := 0; i < valValue.NumField(); i++ {
-		if valValue.Type().Field(i).Tag.Get("bson") == "_id" {
-			if valValue.Field(i).Kind() == reflect.String {
-				refID := valValue.Field(i).String()
-				objID, err = primitive.ObjectIDFromHex(refID)
-				if err != nil {
-					return nil, err
-				}
-			} else {
-				b := valValue.Field(i).Bytes()
-				copy(objID[:], b)
-			}
-			break
-		}
-	}
-
-	if ObjectIDIsZero(objID) {
-		return nil, fmt.Errorf("ObjectID is zero")
+	objID, err := r.refObjectID(tbName, valValue)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Foreign[T]{
@@ -358,25 +385,9 @@ func ToRefListData[T dataType, DT refListType[T]](r *Reference, data DT) (Foreig
 			return nil, fmt.Errorf("struct[%s] is undefined", structName)
 		}
 
-		var objID ObjectID
-		var err error
-		for j := 0; j < elem.NumField(); j++ {
-			if elem.Type().Field(j).Tag.Get("bson") == "_id" {
-				if elem.Field(j).Kind() == reflect.String {
-					refID := elem.Field(j).String()
-					objID, err = primitive.ObjectIDFromHex(refID)
-					if err != nil {
-						return nil, err
-					}
-				} else {
-					b := elem.Field(j).Bytes()
-					copy(objID[:], b)
-				}
-				break
-			}
-		}
-		if ObjectIDIsZero(objID) {
-			return nil, fmt.Errorf("ObjectID is zero")
+		objID, err := r.refObjectID(tbName, elem)
+		if err != nil {
+			return nil, err
 		}
 
 		refList = append(refList, &Foreign[T]{
@@ -387,3 +398,40 @@ func ToRefListData[T dataType, DT refListType[T]](r *Reference, data DT) (Foreig
 
 	return refList, nil
 }
+
+// refObjectID 按表的主键类型读取 struct 的 _id，外键只支持 KeyObjectID 的表
+func (r *Reference) refObjectID(tbName string, elem reflect.Value) (ObjectID, error) {
+	if kt := r.getPrimaryKey(tbName); kt != KeyObjectID {
+		return primitive.NilObjectID, fmt.Errorf("%w: table [%s] primary key type [%d] cannot be referenced, foreign keys only support ObjectID",
+			ErrInvalidQuery, tbName, kt)
+	}
+
+	var objID ObjectID
+	for i := 0; i < elem.NumField(); i++ {
+		sf := elem.Type().Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tags, err := bsoncodec.DefaultStructTagParser.ParseStructTags(sf)
+		if err != nil || tags.Skip || tags.Inline || tags.Name != "_id" {
+			continue
+		}
+
+		switch id := elem.Field(i).Interface().(type) {
+		case string:
+			if objID, err = String2ObjectID(id); err != nil {
+				return primitive.NilObjectID, fmt.Errorf("%w: _id [%s] is not an ObjectID hex", ErrInvalidQuery, id)
+			}
+		case ObjectID:
+			objID = id
+		case []byte:
+			copy(objID[:], id)
+		}
+		break
+	}
+
+	if ObjectIDIsZero(objID) {
+		return primitive.NilObjectID, fmt.Errorf("ObjectID is zero")
+	}
+	return objID, nil
+}
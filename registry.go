@@ -0,0 +1,289 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrRegistryClosed registry 已关闭
+var ErrRegistryClosed = errors.New("[registry]: registry is closed")
+
+// Registry 多集群client注册表，按名称懒加载并缓存client
+type Registry struct {
+	ctx     context.Context
+	appName string
+
+	mu      sync.Mutex
+	closed  bool
+	confs   map[string]*Conf
+	clients map[string]*Client
+	// 正在创建client的名称，避免并发重复创建
+	creating map[string]chan struct{}
+	onCreate func(name string, cli *Client)
+}
+
+// NewRegistry 创建注册表，appName 为所有client的应用名
+func NewRegistry(ctx context.Context, appName string) *Registry {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Registry{
+		ctx:      ctx,
+		appName:  appName,
+		confs:    map[string]*Conf{},
+		clients:  map[string]*Client{},
+		creating: map[string]chan struct{}{},
+	}
+}
+
+// OnCreate 每次创建client（包括重新加载）后调用，用于设置多租户、session检查等
+func (r *Registry) OnCreate(fn func(name string, cli *Client)) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onCreate = fn
+	return r
+}
+
+// Register 注册集群配置，不会立即连接，首次使用时创建client
+// 名称已存在且client已创建时，需要使用 Reload 替换
+func (r *Registry) Register(name string, conf *Conf) error {
+	if name == "" || strings.Contains(name, ".") {
+		return fmt.Errorf("[registry]: name[%s] must not be empty or contain '.'", name)
+	}
+	if conf == nil {
+		return fmt.Errorf("[registry]: conf[%s] is nil", name)
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrRegistryClosed
+	}
+	if _, ok := r.clients[name]; ok {
+		return fmt.Errorf("[registry]: client[%s] is created, use Reload", name)
+	}
+	r.confs[name] = conf
+	return nil
+}
+
+// Names 已注册的集群名称
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.confs))
+	for name := range r.confs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Client 获取集群client，首次调用时创建
+func (r *Registry) Client(name string) (*Client, error) {
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return nil, ErrRegistryClosed
+		}
+		if cli, ok := r.clients[name]; ok {
+			r.mu.Unlock()
+			return cli, nil
+		}
+		conf, ok := r.confs[name]
+		if !ok {
+			r.mu.Unlock()
+			return nil, fmt.Errorf("[registry]: client[%s] not registered", name)
+		}
+		if wait, ok := r.creating[name]; ok {
+			r.mu.Unlock()
+			<-wait
+			continue
+		}
+
+		wait := make(chan struct{})
+		r.creating[name] = wait
+		onCreate := r.onCreate
+		r.mu.Unlock()
+
+		cli, err := r.newClient(name, conf, onCreate)
+
+		r.mu.Lock()
+		delete(r.creating, name)
+		close(wait)
+		if err == nil && (r.closed || r.confs[name] != conf) {
+			// 创建期间注册表已关闭或配置被替换，丢弃新client
+			_ = cli.Close(context.Background())
+			r.mu.Unlock()
+			continue
+		}
+		if err == nil {
+			r.clients[name] = cli
+		}
+		r.mu.Unlock()
+		return cli, err
+	}
+}
+
+func (r *Registry) newClient(name string, conf *Conf, onCreate func(name string, cli *Client)) (*Client, error) {
+	cli, err := NewClientByConf(r.ctx, r.appName, conf)
+	if err != nil {
+		return nil, fmt.Errorf("[registry]: client[%s] %w", name, err)
+	}
+	if onCreate != nil {
+		onCreate(name, cli)
+	}
+	return cli, nil
+}
+
+// splitPath 解析 "cluster.db"，省略db时使用配置中的 DB
+func (r *Registry) splitPath(path string) (name, dbName string, err error) {
+	name, dbName, _ = strings.Cut(path, ".")
+	if dbName != "" {
+		return name, dbName, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conf, ok := r.confs[name]
+	if !ok {
+		return "", "", fmt.Errorf("[registry]: client[%s] not registered", name)
+	}
+	return name, conf.DB, nil
+}
+
+// Database 根据 "cluster.db" 获取数据库，如："analytics.report"，省略db时使用配置中的 DB
+func (r *Registry) Database(path string) (*Database, error) {
+	name, dbName, err := r.splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := r.Client(name)
+	if err != nil {
+		return nil, err
+	}
+	return cli.Database(dbName), nil
+}
+
+// ORM 根据 "cluster.db" 创建ORM，支持多租户数据库路由
+func (r *Registry) ORM(ctx context.Context, path, tbName string, ref *Reference) (*ORM, error) {
+	name, dbName, err := r.splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := r.Client(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewORMByClient(ctx, cli, dbName, tbName, ref), nil
+}
+
+// Reload 使用新配置重新连接集群，如：更换账号密码
+// 新client创建成功后替换旧client，旧client等待执行中的操作结束后关闭，ctx 控制等待时间
+// 已获取的旧 Database、ORM 在旧client关闭后返回 ErrClientClosed，请在每次请求时从注册表获取
+func (r *Registry) Reload(ctx context.Context, name string, conf *Conf) error {
+	if conf == nil {
+		return fmt.Errorf("[registry]: conf[%s] is nil", name)
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRegistryClosed
+	}
+	if _, ok := r.confs[name]; !ok {
+		r.mu.Unlock()
+		return fmt.Errorf("[registry]: client[%s] not registered", name)
+	}
+	old, created := r.clients[name]
+	if !created || reflect.DeepEqual(r.confs[name], conf) {
+		// 未创建client时只替换配置
+		r.confs[name] = conf
+		r.mu.Unlock()
+		return nil
+	}
+	onCreate := r.onCreate
+	r.mu.Unlock()
+
+	cli, err := r.newClient(name, conf, onCreate)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	if r.closed || r.clients[name] != old {
+		r.mu.Unlock()
+		_ = cli.Close(context.Background())
+		return fmt.Errorf("[registry]: client[%s] changed during reload", name)
+	}
+	r.confs[name] = conf
+	r.clients[name] = cli
+	r.mu.Unlock()
+
+	if err = old.Close(ctx); err != nil && !errors.Is(err, ErrClientClosed) {
+		return err
+	}
+	return nil
+}
+
+// Close 关闭所有client，等待执行中的操作结束，返回所有关闭错误
+func (r *Registry) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRegistryClosed
+	}
+	r.closed = true
+	clients := r.clients
+	r.clients = map[string]*Client{}
+	r.mu.Unlock()
+
+	names := make([]string, 0, len(clients))
+	for name := range clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			if err := clients[name].Close(ctx); err != nil && !errors.Is(err, ErrClientClosed) {
+				errs[i] = fmt.Errorf("client[%s] %w", name, err)
+			}
+		}(i, name)
+	}
+	wg.Wait()
+
+	var msgs []string
+	var first error
+	for _, err := range errs {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	if len(msgs) == 1 {
+		return first
+	}
+	return fmt.Errorf("[registry]: %w; %s", first, strings.Join(msgs[1:], "; "))
+}
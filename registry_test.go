@@ -0,0 +1,65 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry(context.Background(), "test")
+	created := 0
+	reg.OnCreate(func(name string, cli *Client) {
+		created++
+	})
+
+	if err := reg.Register("oltp", &Conf{HostMaster: "127.0.0.1:1", DB: "main", ServerSelectionTimeoutMS: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register("bad.name", &Conf{HostMaster: "127.0.0.1:1", DB: "main"}); err == nil {
+		t.Fatal("name with '.' must be error")
+	}
+
+	db, err := reg.Database("oltp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.dbName != "main" {
+		t.Fatalf("db name error: %s", db.dbName)
+	}
+	db2, err := reg.Database("oltp.report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db2.dbName != "report" || db.Client != db2.Client || created != 1 {
+		t.Fatal("client must be cached")
+	}
+	if _, err = reg.Database("archive.main"); err == nil {
+		t.Fatal("unregistered client must be error")
+	}
+
+	err = reg.Reload(context.Background(), "oltp", &Conf{HostMaster: "127.0.0.2:1", DB: "main", ServerSelectionTimeoutMS: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := reg.Client("oltp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cli == db.Client || created != 2 {
+		t.Fatal("client must be reloaded")
+	}
+	if err = db.Ping(nil); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("old client must be closed, got %v", err)
+	}
+
+	if err = reg.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.Ping(nil); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("client must be closed, got %v", err)
+	}
+	if _, err = reg.Client("oltp"); !errors.Is(err, ErrRegistryClosed) {
+		t.Fatalf("expect ErrRegistryClosed, got %v", err)
+	}
+}
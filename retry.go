@@ -0,0 +1,161 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RetryPolicy 操作重试策略，在driver自身的 RetryReads、RetryWrites（最多重试一次）之上生效
+// 只重试读操作和幂等写操作：查询、计数、Distinct、不含 $out/$merge 的聚合、创建索引、
+// UpdateOne/UpdateMany、只包含 $set/$unset/$setOnInsert 的自定义更新、ReplaceOne、DeleteMany
+// 插入、DeleteOne、FindOneAnd*、BulkWrite 不重试，事务中不重试（由事务自身重试）
+type RetryPolicy struct {
+	// MaxAttempts 最大执行次数（包括第一次），小于等于 1 不重试
+	MaxAttempts int
+	// Backoff 第一次重试前的等待时间，之后每次翻倍，默认 100ms
+	Backoff time.Duration
+	// MaxBackoff 最大等待时间，默认 2s
+	MaxBackoff time.Duration
+	// Jitter 等待时间随机浮动比例 [0, 1]，如：0.2 表示 ±20%
+	Jitter float64
+	// Retryable 判断错误是否可以重试，参数为分类后的错误，默认 IsRetryable
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy 默认重试策略：最多执行 3 次，等待 100ms、200ms，±20% 浮动
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     100 * time.Millisecond,
+		MaxBackoff:  2 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// RetryMetrics Metrics 的可选接口，实现后上报重试
+type RetryMetrics interface {
+	// OperationRetry 每次重试前调用，attempt 为即将执行的次数（从 2 开始），err 为上一次的错误
+	OperationRetry(op *Operation, attempt int, err error)
+}
+
+// SetRetryPolicy 设置重试策略，nil 关闭（默认）
+func (c *Client) SetRetryPolicy(p *RetryPolicy) *Client {
+	c.retryPolicy = p
+	return c
+}
+
+// delay 第 n 次重试前的等待时间，n 从 1 开始
+func (p *RetryPolicy) delay(n int) time.Duration {
+	backoff, maxBackoff := p.Backoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = 2 * time.Second
+	}
+
+	d := backoff
+	for i := 1; i < n && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		d = time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+// retry 按重试策略执行 fn，idempotent 为 false 或在事务中时只执行一次
+// ctx 结束时停止重试并返回最后一次的错误
+func (c *Collection) retry(ctx context.Context, name string, idempotent bool, fn func() error) error {
+	p := c.retryPolicy
+	if p == nil || p.MaxAttempts <= 1 || !idempotent || (ctx != nil && mongo.SessionFromContext(ctx) != nil) {
+		return fn()
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !retryable(wrapError(err)) {
+			return err
+		}
+
+		if m, ok := c.metrics.(RetryMetrics); ok {
+			m.OperationRetry(&Operation{
+				Kind:       OperationCollection,
+				Database:   c.dbName,
+				Collection: c.collectionName,
+				Name:       name,
+			}, attempt+1, err)
+		}
+
+		timer := time.NewTimer(p.delay(attempt))
+		select {
+		case <-done:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// idempotentUpdate 更新文档只包含 $set、$unset、$setOnInsert 时重复执行结果不变
+func idempotentUpdate(update map[string]interface{}) bool {
+	for k := range update {
+		switch k {
+		case "$set", "$unset", "$setOnInsert":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// pipelineWrites 聚合管道是否包含写入阶段 $out、$merge
+func pipelineWrites(pipeline []interface{}) bool {
+	for _, stage := range pipeline {
+		var keys []string
+		switch s := stage.(type) {
+		case map[string]interface{}:
+			for k := range s {
+				keys = append(keys, k)
+			}
+		case bson.M:
+			for k := range s {
+				keys = append(keys, k)
+			}
+		case bson.D:
+			for _, e := range s {
+				keys = append(keys, e.Key)
+			}
+		default:
+			// 无法识别的阶段按写入处理，不重试
+			return true
+		}
+		for _, k := range keys {
+			if k == "$out" || k == "$merge" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type testRetryMetrics struct {
+	testMetrics
+	retries []int
+}
+
+func (m *testRetryMetrics) OperationRetry(op *Operation, attempt int, err error) {
+	m.retries = append(m.retries, attempt)
+}
+
+func TestRetry(t *testing.T) {
+	metrics := new(testRetryMetrics)
+	policy := &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	cli := (&Client{}).SetMetrics(metrics).SetRetryPolicy(policy)
+	c := &Collection{Database: &Database{Client: cli, dbName: "test"}, collectionName: "user"}
+
+	netErr := mongo.CommandError{Code: 9001, Labels: []string{"NetworkError"}}
+	calls := 0
+	fn := func() error {
+		calls++
+		return netErr
+	}
+
+	err := c.retry(context.Background(), "FindDocs", true, fn)
+	if !errors.As(err, &mongo.CommandError{}) || calls != 3 || len(metrics.retries) != 2 || metrics.retries[1] != 3 {
+		t.Fatalf("retry error: %v, calls = %d, retries = %v", err, calls, metrics.retries)
+	}
+
+	calls = 0
+	_ = c.retry(context.Background(), "InsertDoc", false, fn)
+	if calls != 1 {
+		t.Fatalf("non idempotent operation retried: %d", calls)
+	}
+
+	calls = 0
+	_ = c.retry(context.Background(), "FindDocs", true, func() error {
+		calls++
+		return mongo.CommandError{Code: 2, Name: "BadValue"}
+	})
+	if calls != 1 {
+		t.Fatalf("non retryable error retried: %d", calls)
+	}
+
+	calls = 0
+	err = c.retry(context.Background(), "FindDocs", true, func() error {
+		calls++
+		if calls < 2 {
+			return netErr
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("retry success: %v, calls = %d", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	_ = c.retry(ctx, "FindDocs", true, fn)
+	if calls != 1 {
+		t.Fatalf("canceled ctx retried: %d", calls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := &RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for n, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 10: time.Second} {
+		if d := p.delay(n); d != want {
+			t.Errorf("delay(%d) = %v, want %v", n, d, want)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.delay(1); d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("jitter delay = %v", d)
+		}
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	if !idempotentUpdate(bson.M{"$set": bson.M{"a": 1}, "$unset": bson.M{"b": ""}}) || idempotentUpdate(bson.M{"$inc": bson.M{"a": 1}}) {
+		t.Error("idempotent update error")
+	}
+	if pipelineWrites([]interface{}{bson.M{"$match": bson.M{}}, bson.D{{Key: "$group", Value: bson.M{}}}}) {
+		t.Error("read pipeline treated as write")
+	}
+	if !pipelineWrites([]interface{}{bson.M{"$match": bson.M{}}, bson.D{{Key: "$merge", Value: "other"}}}) {
+		t.Error("$merge pipeline treated as read")
+	}
+}
@@ -0,0 +1,258 @@
+// Package mongo
+package mongo
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+)
+
+// tracker ToData 加载的 struct 快照，key 为 struct 指针
+type tracker struct {
+	mu        sync.Mutex
+	snapshots map[interface{}]map[string]interface{}
+}
+
+// Track 开启脏数据跟踪：ToData 加载的 struct（包括列表中的元素）保存快照，Save 时只更新变化的字段
+// 快照保存在ORM中，ORM 不再使用时一并释放；列表元素使用 &list[i] 调用 Save
+func (orm *ORM) Track() *ORM {
+	o := orm.builder()
+	if o.tracker == nil {
+		o.tracker = &tracker{snapshots: map[interface{}]map[string]interface{}{}}
+	}
+	return o
+}
+
+// Untrack 删除 model 的快照，之后 Save 替换整个文档
+func (orm *ORM) Untrack(model interface{}) {
+	if orm.tracker == nil {
+		return
+	}
+	orm.tracker.mu.Lock()
+	delete(orm.tracker.snapshots, model)
+	orm.tracker.mu.Unlock()
+}
+
+func (t *tracker) get(model interface{}) map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshots[model]
+}
+
+func (t *tracker) set(model interface{}, doc map[string]interface{}) {
+	t.mu.Lock()
+	t.snapshots[model] = doc
+	t.mu.Unlock()
+}
+
+// snapshot 保存 ToData 结果中 struct 的快照，没有 _id 的 struct 不保存
+func (t *tracker) snapshot(registry *bsoncodec.Registry, target reflect.Value) error {
+	var models []interface{}
+	switch target.Kind() {
+	case reflect.Struct:
+		models = append(models, target.Addr().Interface())
+	case reflect.Slice:
+		for i := 0; i < target.Len(); i++ {
+			elem := target.Index(i)
+			if elem.Kind() == reflect.Struct {
+				models = append(models, elem.Addr().Interface())
+			} else if elem.Kind() == reflect.Ptr && !elem.IsNil() && elem.Elem().Kind() == reflect.Struct {
+				models = append(models, elem.Interface())
+			}
+		}
+	}
+
+	for _, model := range models {
+		doc, err := toDocument(registry, model)
+		if err != nil {
+			return err
+		}
+		if _, ok := doc["_id"]; ok {
+			t.set(model, doc)
+		}
+	}
+	return nil
+}
+
+// Save 保存 struct 指针
+// _id 为空时插入并回写 _id；Track 模式下已加载的 struct 只更新变化的字段（$set、$unset，嵌套文档按路径比较），
+// 没有变化时不执行；否则按 _id 替换整个文档，不存在时插入
+// 条件只使用 _id 与表的默认作用域
+func (orm *ORM) Save(model interface{}) (err error) {
+	op := orm.observe("Save", &err)
+	defer op.end()
+	modelValue := reflect.ValueOf(model)
+	if model == nil || modelValue.Kind() != reflect.Ptr || modelValue.IsNil() || modelValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: save model must be struct ptr", ErrInvalidQuery)
+	}
+
+	table, err := orm.table()
+	if err != nil {
+		return err
+	}
+
+	kt := orm.refConf.getPrimaryKey(orm.tableName)
+	m := Struct2Map(model)
+	if _, ok := m["_id"]; !ok {
+		if err = kt.formatInsertID(m); err != nil {
+			return err
+		}
+		if _, ok = m["_id"]; !ok {
+			m["_id"] = NewObjectID()
+		}
+		if _, err = table.InsertDoc(op.ctx, m); err != nil {
+			return err
+		}
+		setModelID(modelValue.Elem(), m["_id"])
+		return orm.trackModel(model)
+	}
+
+	q := MixQ(orm.formatWhere(orm.tableName, orm.scopedWhere(orm.tableName, Where{"_id": m["_id"]})))
+	op.q = q
+	var old map[string]interface{}
+	if orm.tracker != nil {
+		old = orm.tracker.get(model)
+	}
+	if old != nil {
+		cur, err := toDocument(orm.db.codecRegistry(), model)
+		if err != nil {
+			return err
+		}
+		set, unset := map[string]interface{}{}, map[string]interface{}{}
+		diffDocument("", old, cur, set, unset)
+		if len(set) <= 0 && len(unset) <= 0 {
+			return nil
+		}
+
+		ret, err := table.UpdateOneOps(op.ctx, q, UpdateOps{UpdateSet: set, UpdateUnset: unset}, NewUpdate())
+		if err != nil {
+			return err
+		}
+		if ret.MatchedCount <= 0 {
+			return fmt.Errorf("%w: save document _id[%v] is not exist", ErrNotFound, m["_id"])
+		}
+		orm.tracker.set(model, cur)
+		return nil
+	}
+
+	if err = kt.formatInsertID(m); err != nil {
+		return err
+	}
+	opt := NewReplace()
+	opt.Upsert(true)
+	if _, err = table.ReplaceOne(op.ctx, q, m, opt); err != nil {
+		return err
+	}
+	return orm.trackModel(model)
+}
+
+func (orm *ORM) trackModel(model interface{}) error {
+	if orm.tracker == nil {
+		return nil
+	}
+	doc, err := toDocument(orm.db.codecRegistry(), model)
+	if err != nil {
+		return err
+	}
+	orm.tracker.set(model, doc)
+	return nil
+}
+
+// codecRegistry client 的编码解码器，未通过 NewClient 创建时使用默认配置
+func (c *Client) codecRegistry() *bsoncodec.Registry {
+	if c == nil || c.registry == nil {
+		return register(nil)
+	}
+	return c.registry
+}
+
+// toDocument 按driver编码后解码为 map，嵌套文档为 map[string]interface{}
+func toDocument(registry *bsoncodec.Registry, v interface{}) (map[string]interface{}, error) {
+	raw, err := bson.MarshalWithRegistry(registry, v)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	if err = bson.UnmarshalWithRegistry(registry, raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// diffDocument 计算 old 到 cur 的更新：新增、变化的字段加入 set，删除的字段加入 unset
+// 两边都是文档（外键除外）时按路径递归比较，_id 不比较
+func diffDocument(prefix string, old, cur map[string]interface{}, set, unset map[string]interface{}) {
+	for k, v := range cur {
+		if prefix == "" && k == "_id" {
+			continue
+		}
+		path := prefix + k
+		oldV, ok := old[k]
+		if !ok {
+			set[path] = v
+			continue
+		}
+
+		oldDoc, oldIsDoc := oldV.(map[string]interface{})
+		curDoc, curIsDoc := v.(map[string]interface{})
+		if oldIsDoc && curIsDoc && !isDBRef(oldDoc) && !isDBRef(curDoc) {
+			diffDocument(path+".", oldDoc, curDoc, set, unset)
+			continue
+		}
+		if !reflect.DeepEqual(oldV, v) {
+			set[path] = v
+		}
+	}
+
+	for k := range old {
+		if prefix == "" && k == "_id" {
+			continue
+		}
+		if _, ok := cur[k]; !ok {
+			unset[prefix+k] = ""
+		}
+	}
+}
+
+// isDBRef 外键（$ref、$id）整体更新
+func isDBRef(doc map[string]interface{}) bool {
+	_, ok := doc["$ref"]
+	return ok
+}
+
+// setModelID 插入后回写 _id，ObjectID、UUID 可以回写到 string 字段
+func setModelID(model reflect.Value, id interface{}) {
+	tp := model.Type()
+	for i := 0; i < tp.NumField(); i++ {
+		sf := tp.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tags, err := bsoncodec.DefaultStructTagParser.ParseStructTags(sf)
+		if err != nil || tags.Skip || tags.Inline || tags.Name != "_id" {
+			continue
+		}
+
+		field := model.Field(i)
+		if field.Kind() == reflect.String {
+			switch v := id.(type) {
+			case ObjectID:
+				field.SetString(v.Hex())
+				return
+			case UUID:
+				field.SetString(v.String())
+				return
+			}
+		}
+		idValue := reflect.ValueOf(id)
+		if idValue.Type().AssignableTo(field.Type()) {
+			field.Set(idValue)
+		} else if field.Kind() != reflect.String && idValue.Type().ConvertibleTo(field.Type()) {
+			field.Set(idValue.Convert(field.Type()))
+		}
+		return
+	}
+}
@@ -0,0 +1,73 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+)
+
+type saveAddress struct {
+	City   string `bson:"city"`
+	Street string `bson:"street,omitempty"`
+}
+
+type saveModel struct {
+	ID      string        `bson:"_id"`
+	Name    string        `bson:"name"`
+	Tags    []string      `bson:"tags"`
+	Note    string        `bson:"note,omitempty"`
+	Address saveAddress   `bson:"address"`
+	Ref     *Foreign[tb3] `bson:"ref,omitempty"`
+}
+
+func TestSaveDiff(t *testing.T) {
+	registry := register(nil)
+	list := []saveModel{{
+		ID:      "62788a8e92961d9287c6b8d2",
+		Name:    "a",
+		Tags:    []string{"x"},
+		Note:    "note",
+		Address: saveAddress{City: "sh", Street: "s1"},
+		Ref:     &Foreign[tb3]{Ref: "test3", ID: NewObjectID()},
+	}}
+	tr := &tracker{snapshots: map[interface{}]map[string]interface{}{}}
+	if err := tr.snapshot(registry, reflect.ValueOf(list)); err != nil {
+		t.Fatal(err)
+	}
+	old := tr.get(&list[0])
+	if old == nil {
+		t.Fatal("list element must be tracked")
+	}
+
+	model := &list[0]
+	model.Name = "b"
+	model.Note = ""
+	model.Tags = append(model.Tags, "y")
+	model.Address.Street = ""
+	model.Ref = &Foreign[tb3]{Ref: "test3", ID: NewObjectID()}
+	cur, err := toDocument(registry, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, unset := map[string]interface{}{}, map[string]interface{}{}
+	diffDocument("", old, cur, set, unset)
+	if len(set) != 3 || set["name"] != "b" || set["tags"] == nil || set["ref"] == nil {
+		t.Fatalf("set error: %v", set)
+	}
+	if len(unset) != 2 || unset["note"] != "" || unset["address.street"] != "" {
+		t.Fatalf("unset error: %v", unset)
+	}
+
+	set, unset = map[string]interface{}{}, map[string]interface{}{}
+	diffDocument("", cur, cur, set, unset)
+	if len(set) != 0 || len(unset) != 0 {
+		t.Fatalf("no change must be empty: %v, %v", set, unset)
+	}
+
+	var m saveModel
+	id := NewObjectID()
+	setModelID(reflect.ValueOf(&m).Elem(), id)
+	if m.ID != id.Hex() {
+		t.Fatalf("set model id error: %s", m.ID)
+	}
+}
@@ -0,0 +1,132 @@
+// Package mongo
+package mongo
+
+import "fmt"
+
+// Scope 查询作用域，对ORM追加通用的查询条件
+// 如：租户过滤、仅查询有效数据、按时间倒序等
+type Scope func(orm *ORM) *ORM
+
+// Scopes 应用查询作用域
+func (orm *ORM) Scopes(scopes ...Scope) *ORM {
+	o := orm
+	for _, scope := range scopes {
+		o = scope(o)
+	}
+	return o
+}
+
+// Unscoped 忽略表的默认作用域
+func (orm *ORM) Unscoped() *ORM {
+	o := orm.builder()
+	o.unscoped = true
+	return o
+}
+
+// scoped 返回应用了默认作用域的ORM副本，不修改原ORM
+// 默认作用域的条件与用户条件同时生效，只能通过 Unscoped 忽略，如：Unscoped().Where("deleted", true) 查询已删除的数据
+func (orm *ORM) scoped() *ORM {
+	if orm.unscoped || orm.refConf == nil {
+		return orm
+	}
+
+	scopes := orm.refConf.getDefaultScopes(orm.tableName)
+	if len(scopes) <= 0 {
+		return orm
+	}
+	o := orm.Clone()
+	where := o.Q.Where
+	o.Q.Where = Where{}
+	o = o.Scopes(scopes...)
+	o.Q.Where = mergeScopeWhere(where, o.Q.Where)
+	return o
+}
+
+// scopedWhere 外键子查询应用关联表的默认作用域
+func (orm *ORM) scopedWhere(tbName string, where Where) Where {
+	if orm.unscoped || orm.refConf == nil {
+		return where
+	}
+
+	scopes := orm.refConf.getDefaultScopes(tbName)
+	if len(scopes) <= 0 {
+		return where
+	}
+
+	sub := &ORM{
+		ctx:       orm.ctx,
+		refConf:   orm.refConf,
+		db:        orm.db,
+		tableName: tbName,
+		keepQuery: true,
+		Q:         newMongoOrmQ(),
+	}
+	return mergeScopeWhere(where, sub.Scopes(scopes...).Q.Where)
+}
+
+// mergeScopeWhere 合并用户条件与默认作用域的条件，返回新的 Where
+// 同名的条件两者都追加到 $and 中同时生效，用户条件不能覆盖默认作用域，如：租户作用域下 Where("tenant_id", other) 查询不到其他租户的数据
+func mergeScopeWhere(where, scope Where) Where {
+	merged := make(Where, len(where)+len(scope))
+	for k, v := range where {
+		merged[k] = v
+	}
+	var and []interface{}
+	for k, v := range scope {
+		if _, ok := merged[k]; !ok {
+			merged[k] = v
+			continue
+		}
+		switch k {
+		case "$and":
+			and = append(and, andList(v)...)
+		case "$or", "$nor":
+			and = append(and, map[string]interface{}{k: v})
+		default:
+			and = append(and, map[string]interface{}{k: merged[k]}, map[string]interface{}{k: v})
+			delete(merged, k)
+		}
+	}
+	if len(and) > 0 {
+		if old, ok := merged["$and"]; ok {
+			and = append(andList(old), and...)
+		}
+		merged["$and"] = and
+	}
+	return merged
+}
+
+// andList $and 的值转换为 []interface{}，格式同 MixQ
+func andList(v interface{}) []interface{} {
+	var arr []interface{}
+	switch v := v.(type) {
+	case []interface{}:
+		arr = append(arr, v...)
+	case []map[string]interface{}:
+		for _, m := range v {
+			arr = append(arr, m)
+		}
+	case []*Query:
+		for _, q := range v {
+			arr = append(arr, q)
+		}
+	case map[string]interface{}, *Query:
+		arr = append(arr, v)
+	default:
+		panic("$and value must be map[string]interface{} or []map[string]interface{} or []*MongoQuery or mix type")
+	}
+	return arr
+}
+
+// AddDefaultScope 添加表的默认作用域，表的查询、更新、删除以及外键子查询均自动应用
+// 可通过 ORM.Unscoped() 忽略
+func (r *Reference) AddDefaultScope(tbName string, scopes ...Scope) {
+	if _, ok := r.tableDef[tbName]; !ok {
+		panic(fmt.Sprintf("collection [%s] is not defined", tbName))
+	}
+	r.defaultScopes[tbName] = append(r.defaultScopes[tbName], scopes...)
+}
+
+func (r *Reference) getDefaultScopes(tbName string) []Scope {
+	return r.defaultScopes[tbName]
+}
@@ -0,0 +1,93 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrSessionNotBound 事务执行期间使用了未绑定事务的ORM
+var ErrSessionNotBound = errors.New("[session]: orm is not bound to the running transaction, use WithSession")
+
+// SessionCheck 事务绑定检查方式
+type SessionCheck int
+
+const (
+	// SessionCheckOff 不检查（默认）
+	SessionCheckOff SessionCheck = iota
+	// SessionCheckWarn 输出警告日志
+	SessionCheckWarn
+	// SessionCheckError 返回 ErrSessionNotBound
+	SessionCheckError
+)
+
+// SetSessionCheck 设置事务绑定检查方式
+// 当ORM的ctx是执行中的事务的父 ctx（NewSessionContext 的 ctx），且未绑定session时触发
+// client的 ctx、context.Background()、context.TODO() 为共享 ctx 不检查（NewSession 不检查），其他 ctx 的并发请求不受影响
+// 建议使用请求的 ctx 调用 NewSessionContext
+func (c *Client) SetSessionCheck(check SessionCheck) *Client {
+	c.sessionCheck = check
+	return c
+}
+
+// trackTx 是否记录事务的父 ctx，不可比较的 ctx 与共享 ctx 不记录，否则会影响使用共享 ctx 的无关请求
+func (c *Client) trackTx(ctx context.Context) bool {
+	if ctx == nil || !reflect.TypeOf(ctx).Comparable() {
+		return false
+	}
+	return ctx != c.ctx && ctx != context.Background() && ctx != context.TODO()
+}
+
+// enterTx 记录事务的父 ctx
+func (c *Client) enterTx(ctx context.Context) {
+	if !c.trackTx(ctx) {
+		return
+	}
+	c.txMu.Lock()
+	if c.txCtxs == nil {
+		c.txCtxs = map[context.Context]int{}
+	}
+	c.txCtxs[ctx]++
+	c.txMu.Unlock()
+}
+
+func (c *Client) leaveTx(ctx context.Context) {
+	if !c.trackTx(ctx) {
+		return
+	}
+	c.txMu.Lock()
+	if c.txCtxs[ctx] <= 1 {
+		delete(c.txCtxs, ctx)
+	} else {
+		c.txCtxs[ctx]--
+	}
+	c.txMu.Unlock()
+}
+
+// inTx ctx 是否为执行中的事务的父 ctx
+func (c *Client) inTx(ctx context.Context) bool {
+	if !c.trackTx(ctx) {
+		return false
+	}
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+	return c.txCtxs[ctx] > 0
+}
+
+func (c *Client) checkSession(ctx context.Context, tbName string) error {
+	if c.sessionCheck == SessionCheckOff {
+		return nil
+	}
+	if ctx == nil || mongo.SessionFromContext(ctx) != nil || !c.inTx(ctx) {
+		return nil
+	}
+
+	if c.sessionCheck == SessionCheckWarn {
+		c.Logger().Warn("mongo orm is used during a transaction without session, use WithSession", "table", tbName)
+		return nil
+	}
+	return ErrSessionNotBound
+}
@@ -0,0 +1,85 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCheckSession(t *testing.T) {
+	c := &Client{}
+	c.SetSessionCheck(SessionCheckError)
+	if err := c.checkSession(context.Background(), "test"); err != nil {
+		t.Fatalf("no transaction running, got %v", err)
+	}
+
+	txCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.enterTx(txCtx)
+	if err := c.checkSession(txCtx, "test"); !errors.Is(err, ErrSessionNotBound) {
+		t.Fatalf("expect ErrSessionNotBound, got %v", err)
+	}
+	// 其他 ctx 的并发请求不受事务影响
+	if err := c.checkSession(context.Background(), "test"); err != nil {
+		t.Fatalf("unrelated ctx must pass, got %v", err)
+	}
+
+	c.SetSessionCheck(SessionCheckWarn)
+	if err := c.checkSession(txCtx, "test"); err != nil {
+		t.Fatalf("warn mode must not return error, got %v", err)
+	}
+
+	c.SetSessionCheck(SessionCheckError)
+	c.leaveTx(txCtx)
+	if err := c.checkSession(txCtx, "test"); err != nil {
+		t.Fatalf("transaction finished, got %v", err)
+	}
+}
+
+func TestCheckSessionSharedCtx(t *testing.T) {
+	cli, err := NewClient(context.Background(), OptionsFromURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close(context.Background())
+	cli.SetSessionCheck(SessionCheckError)
+
+	// client的 ctx 是共享 ctx，不记录
+	cli.enterTx(cli.ctx)
+	if err = cli.checkSession(context.Background(), "test"); err != nil {
+		t.Fatalf("shared ctx must pass, got %v", err)
+	}
+	cli.leaveTx(cli.ctx)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	txErr := make(chan error, 1)
+	go func() {
+		txErr <- cli.NewSession(func(sessionCtx SessionContext) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	// NewSession 执行期间，其他 goroutine 使用client ctx 的ORM不受影响
+	for _, ctx := range []context.Context{nil, context.Background()} {
+		if _, err = NewORMByClient(ctx, cli, "test", "test", nil).table(); err != nil {
+			t.Errorf("orm on shared ctx must pass, got %v", err)
+		}
+	}
+	close(release)
+	if err = <-txErr; err != nil {
+		t.Fatal(err)
+	}
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = cli.NewSessionContext(reqCtx, func(sessionCtx SessionContext) error {
+		_, err := NewORMByClient(reqCtx, cli, "test", "test", nil).table()
+		return err
+	})
+	if !errors.Is(err, ErrSessionNotBound) {
+		t.Fatalf("expect ErrSessionNotBound, got %v", err)
+	}
+}
@@ -31,4 +31,11 @@ type BulkWriteResult struct {
 
 	// A map of operation index to the _id of each upserted document.
 	UpsertedIDs map[int64]interface{}
+
+	// The outcome of each operation added by AddUpsertBy, inserted or updated.
+	// Failed and unexecuted operations are not included.
+	Upserts []UpsertOutcome
+
+	// The failed operations, the same as ErrBulkPartial.Failures.
+	Failures []BulkFailure
 }
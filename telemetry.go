@@ -0,0 +1,152 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// 操作类型
+const (
+	OperationCollection = "collection"
+	OperationORM        = "orm"
+)
+
+// Operation 被观测的操作
+type Operation struct {
+	// Kind 操作类型：OperationCollection、OperationORM
+	Kind     string
+	Database string
+	// Collection 集合名称，ORM 中为表名
+	Collection string
+	// Name 方法名称，如：FindDocs、UpdateOne、ToData
+	Name string
+}
+
+// Metrics 指标接口，每个 Collection 操作、ORM 终结方法结束时调用 OperationDone
+type Metrics interface {
+	OperationDone(op *Operation, d time.Duration, err error)
+	// PoolCheckout 获取连接结束时调用，wait 为近似的等待时间（同一地址按先后顺序匹配开始事件）
+	PoolCheckout(address string, wait time.Duration, err error)
+}
+
+// Span 追踪span
+type Span interface {
+	End(err error)
+}
+
+// Tracer 追踪接口，每个 Collection 操作、ORM 终结方法开始时调用 StartSpan
+// 返回的 ctx 会传递给 driver，ORM 与 Collection 的 span 均为 ORM ctx 的子节点
+type Tracer interface {
+	StartSpan(ctx context.Context, op *Operation) (context.Context, Span)
+}
+
+// SetMetrics 设置指标，nil 关闭
+func (c *Client) SetMetrics(m Metrics) *Client {
+	c.metrics = m
+	if c.monitor != nil {
+		c.monitor.hookMu.Lock()
+		c.monitor.metrics = m
+		c.monitor.hookMu.Unlock()
+	}
+	return c
+}
+
+// SetTracer 设置追踪，nil 关闭
+func (c *Client) SetTracer(t Tracer) *Client {
+	c.tracer = t
+	return c
+}
+
+// observe 开始观测操作，返回的函数需要在操作结束时调用
+func (c *Client) observe(ctx context.Context, op *Operation) (context.Context, func(err error)) {
+	metrics, tracer := c.metrics, c.tracer
+	if metrics == nil && tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	var span Span
+	if tracer != nil {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, span = tracer.StartSpan(ctx, op)
+	}
+	start := time.Now()
+	return ctx, func(err error) {
+		if metrics != nil {
+			metrics.OperationDone(op, time.Since(start), err)
+		}
+		if span != nil {
+			span.End(err)
+		}
+	}
+}
+
+// start 开始集合操作：检查client状态、确定上下文并开始观测
+// 返回的 end 需要在操作结束时调用，会对错误进行分类
+func (c *Collection) start(ctx context.Context, name string) (context.Context, func(err *error), error) {
+	if c.optsErr != nil {
+		return nil, nil, c.optsErr
+	}
+	ctxObj := c.ctx
+	if ctx != nil {
+		ctxObj = ctx
+	}
+	done, err := c.begin(ctxObj)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctxObj, finish := c.observe(ctxObj, &Operation{
+		Kind:       OperationCollection,
+		Database:   c.dbName,
+		Collection: c.collectionName,
+		Name:       name,
+	})
+	return ctxObj, func(err *error) {
+		*err = wrapError(*err)
+		finish(*err)
+		done()
+	}, nil
+}
+
+// checkout 根据连接池事件计算获取连接的等待时间
+func (m *clientMonitor) checkout(e *event.PoolEvent) {
+	m.hookMu.RLock()
+	metrics := m.metrics
+	m.hookMu.RUnlock()
+	if metrics == nil {
+		return
+	}
+
+	switch e.Type {
+	case event.GetStarted:
+		m.checkoutMu.Lock()
+		if m.checkouts == nil {
+			m.checkouts = map[string][]time.Time{}
+		}
+		m.checkouts[e.Address] = append(m.checkouts[e.Address], time.Now())
+		m.checkoutMu.Unlock()
+	case event.GetSucceeded, event.GetFailed:
+		var wait time.Duration
+		m.checkoutMu.Lock()
+		if starts := m.checkouts[e.Address]; len(starts) > 0 {
+			wait = time.Since(starts[0])
+			if len(starts) == 1 {
+				delete(m.checkouts, e.Address)
+			} else {
+				m.checkouts[e.Address] = starts[1:]
+			}
+		}
+		m.checkoutMu.Unlock()
+
+		var err error
+		if e.Type == event.GetFailed {
+			err = fmt.Errorf("connection checkout failed: %s", e.Reason)
+		}
+		metrics.PoolCheckout(e.Address, wait, err)
+	}
+}
@@ -0,0 +1,122 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+type testMetrics struct {
+	ops       []string
+	errs      int
+	checkouts []time.Duration
+}
+
+func (m *testMetrics) OperationDone(op *Operation, d time.Duration, err error) {
+	m.ops = append(m.ops, op.Kind+":"+op.Database+"."+op.Collection+"."+op.Name)
+	if err != nil {
+		m.errs++
+	}
+}
+
+func (m *testMetrics) PoolCheckout(address string, wait time.Duration, err error) {
+	m.checkouts = append(m.checkouts, wait)
+}
+
+type testSpanKey struct{}
+
+type testTracer struct {
+	ended []error
+}
+
+func (t *testTracer) StartSpan(ctx context.Context, op *Operation) (context.Context, Span) {
+	return context.WithValue(ctx, testSpanKey{}, op.Name), testSpan{t}
+}
+
+type testSpan struct {
+	t *testTracer
+}
+
+func (s testSpan) End(err error) {
+	s.t.ended = append(s.t.ended, err)
+}
+
+func TestTelemetry(t *testing.T) {
+	metrics, tracer := new(testMetrics), new(testTracer)
+	cli := (&Client{}).SetMetrics(metrics).SetTracer(tracer)
+	db := &Database{Client: cli, ctx: context.Background(), dbName: "test"}
+	c := &Collection{Database: db, ctx: db.ctx, collectionName: "user"}
+
+	ctx, end, err := c.start(nil, "FindDocs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Value(testSpanKey{}) != "FindDocs" {
+		t.Error("span context not passed to driver")
+	}
+	err = errors.New("boom")
+	end(&err)
+
+	orm := &ORM{db: db, ctx: db.ctx, refConf: NewReference(), tableName: "user", Q: newMongoOrmQ()}
+	err = nil
+	op := orm.observe("ToData", &err)
+	// 集合操作使用 ORM 操作的 span 上下文
+	if op.ctx.Value(testSpanKey{}) != "ToData" {
+		t.Error("orm span context not passed to collection")
+	}
+	op.end()
+
+	want := []string{"collection:test.user.FindDocs", "orm:test.user.ToData"}
+	if len(metrics.ops) != 2 || metrics.ops[0] != want[0] || metrics.ops[1] != want[1] || metrics.errs != 1 {
+		t.Fatalf("metrics = %v, errs = %d", metrics.ops, metrics.errs)
+	}
+	if len(tracer.ended) != 2 || tracer.ended[0] == nil || tracer.ended[1] != nil {
+		t.Fatalf("spans = %v", tracer.ended)
+	}
+}
+
+func TestPageDataTelemetry(t *testing.T) {
+	cli, err := NewClient(context.Background(), OptionsFromURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close(context.Background())
+	metrics := new(testMetrics)
+	cli.SetMetrics(metrics)
+
+	var target []map[string]interface{}
+	if _, err = NewORMByClient(context.Background(), cli, "test", "user", nil).PageData(&target, 1, 10); err == nil {
+		t.Fatal("page data must fail without server")
+	}
+	// 内部的 Count、ToData 不单独记录
+	if n := len(metrics.ops); n <= 0 || metrics.ops[n-1] != "orm:test.user.PageData" {
+		t.Fatalf("page data not observed: %v", metrics.ops)
+	}
+	for _, op := range metrics.ops {
+		if strings.HasPrefix(op, "orm:") && op != "orm:test.user.PageData" {
+			t.Fatalf("nested orm operation observed: %v", metrics.ops)
+		}
+	}
+}
+
+func TestPoolCheckoutMetrics(t *testing.T) {
+	metrics := new(testMetrics)
+	m := new(clientMonitor)
+	m.metrics = metrics
+
+	m.checkout(&event.PoolEvent{Type: event.GetStarted, Address: "a"})
+	time.Sleep(time.Millisecond)
+	m.checkout(&event.PoolEvent{Type: event.GetSucceeded, Address: "a"})
+	m.checkout(&event.PoolEvent{Type: event.GetFailed, Address: "b"})
+
+	if len(metrics.checkouts) != 2 || metrics.checkouts[0] < time.Millisecond || metrics.checkouts[1] != 0 {
+		t.Fatalf("checkouts = %v", metrics.checkouts)
+	}
+	if len(m.checkouts) != 0 {
+		t.Fatalf("pending checkouts = %v", m.checkouts)
+	}
+}
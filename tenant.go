@@ -0,0 +1,324 @@
+// Package mongo
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrTenantRequired 开启多租户后，ctx 中缺少租户信息
+	ErrTenantRequired = errors.New("[tenant]: tenant is required in context")
+	// ErrTenantMismatch 写入数据的租户与 ctx 中的租户不一致
+	ErrTenantMismatch = errors.New("[tenant]: document tenant mismatch")
+)
+
+type tenantCtxKey struct{}
+
+type ignoreTenantCtxKey struct{}
+
+// TenantConf 多租户配置
+type TenantConf struct {
+	// Field 租户字段，如：tenant_id
+	// 非空时所有查询、更新、删除自动追加租户条件，插入的数据自动写入租户字段
+	Field string
+	// Ignore 不区分租户的集合
+	Ignore []string
+	// Database 按租户路由数据库，返回实际使用的数据库名，nil 不路由
+	Database func(dbName string, tenant interface{}) string
+
+	ignore map[string]struct{}
+}
+
+// SetTenant 开启多租户模式，开启后 ctx 中缺少租户的操作均返回 ErrTenantRequired
+// conf 为 nil 时关闭
+func (c *Client) SetTenant(conf *TenantConf) *Client {
+	if conf != nil {
+		conf.ignore = map[string]struct{}{}
+		for _, name := range conf.Ignore {
+			conf.ignore[name] = struct{}{}
+		}
+	}
+	c.tenant = conf
+	return c
+}
+
+// TenantDatabase 根据 ctx 中的租户获取数据库，未配置路由时与 Database 一致
+func (c *Client) TenantDatabase(ctx context.Context, dbName string) (*Database, error) {
+	if c.tenant == nil || c.tenant.Database == nil {
+		return c.Database(dbName), nil
+	}
+
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrTenantRequired
+	}
+	return c.Database(c.tenant.Database(dbName, tenant)), nil
+}
+
+// WithTenant ctx 中写入租户，事务上下文会保留 session
+func WithTenant(ctx context.Context, tenant interface{}) context.Context {
+	newCtx := context.WithValue(ctx, tenantCtxKey{}, tenant)
+	if sess := mongo.SessionFromContext(ctx); sess != nil {
+		return mongo.NewSessionContext(newCtx, sess)
+	}
+	return newCtx
+}
+
+// TenantFromContext 获取 ctx 中的租户
+func TenantFromContext(ctx context.Context) (interface{}, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	tenant := ctx.Value(tenantCtxKey{})
+	return tenant, tenant != nil
+}
+
+// IgnoreTenant 忽略租户限制，用于数据迁移、统计等跨租户操作
+func IgnoreTenant(ctx context.Context) context.Context {
+	newCtx := context.WithValue(ctx, ignoreTenantCtxKey{}, true)
+	if sess := mongo.SessionFromContext(ctx); sess != nil {
+		return mongo.NewSessionContext(newCtx, sess)
+	}
+	return newCtx
+}
+
+// tenantOf 获取当前操作的租户，enabled 为 false 表示不需要租户限制
+func (c *Collection) tenantOf(ctx context.Context) (tenant interface{}, enabled bool, err error) {
+	conf := c.Database.Client.tenant
+	if conf == nil {
+		return nil, false, nil
+	}
+	if _, ok := conf.ignore[c.collectionName]; ok {
+		return nil, false, nil
+	}
+	if ctx != nil && ctx.Value(ignoreTenantCtxKey{}) != nil {
+		return nil, false, nil
+	}
+
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, true, ErrTenantRequired
+	}
+	return tenant, true, nil
+}
+
+// cond 查询条件，开启多租户时追加租户条件
+func (c *Collection) cond(ctx context.Context, filter *Query) (map[string]interface{}, error) {
+	if filter == nil {
+		filter = NewQuery()
+	}
+	m := filter.Cond()
+
+	tenant, enabled, err := c.tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled || c.Database.Client.tenant.Field == "" {
+		return m, nil
+	}
+	return tenantCond(m, c.Database.Client.tenant.Field, tenant), nil
+}
+
+func tenantCond(filter interface{}, field string, tenant interface{}) map[string]interface{} {
+	m, ok := filter.(map[string]interface{})
+	if filter == nil || (ok && len(m) <= 0) {
+		return map[string]interface{}{field: tenant}
+	}
+
+	if !ok {
+		return map[string]interface{}{
+			"$and": []interface{}{filter, map[string]interface{}{field: tenant}},
+		}
+	}
+
+	if _, has := m[field]; has {
+		return map[string]interface{}{
+			"$and": []interface{}{m, map[string]interface{}{field: tenant}},
+		}
+	}
+
+	newM := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		newM[k] = v
+	}
+	newM[field] = tenant
+	return newM
+}
+
+// stampTenant 写入的数据追加租户字段，返回新的文档
+func (c *Collection) stampTenant(ctx context.Context, doc map[string]interface{}) (map[string]interface{}, error) {
+	tenant, enabled, err := c.tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	field := ""
+	if enabled {
+		field = c.Database.Client.tenant.Field
+	}
+	if field == "" || doc == nil {
+		return doc, nil
+	}
+
+	if old, ok := doc[field]; ok {
+		if !reflect.DeepEqual(old, tenant) {
+			return nil, fmt.Errorf("%w: %v != %v", ErrTenantMismatch, old, tenant)
+		}
+		return doc, nil
+	}
+
+	newDoc := make(map[string]interface{}, len(doc)+1)
+	for k, v := range doc {
+		newDoc[k] = v
+	}
+	newDoc[field] = tenant
+	return newDoc, nil
+}
+
+func (c *Collection) stampTenantDoc(ctx context.Context, doc interface{}) (interface{}, error) {
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		return c.stampTenant(ctx, d)
+	default:
+		if d == nil {
+			return d, nil
+		}
+		tp := reflect.TypeOf(d)
+		if tp.Kind() == reflect.Ptr {
+			tp = tp.Elem()
+		}
+		if tp.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("[tenant]: document type must be map[string]interface{} or struct, got %T", d)
+		}
+		return c.stampTenant(ctx, Struct2Map(d))
+	}
+}
+
+func (c *Collection) stampTenantDocs(ctx context.Context, docs []interface{}) ([]interface{}, error) {
+	if _, enabled, err := c.tenantOf(ctx); err != nil || !enabled {
+		return docs, err
+	}
+
+	newDocs := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		newDoc, err := c.stampTenantDoc(ctx, doc)
+		if err != nil {
+			return nil, err
+		}
+		newDocs[i] = newDoc
+	}
+	return newDocs, nil
+}
+
+// tenantModels 批量写入模型追加租户条件，返回新的模型，不修改 BulkWriteModel
+func (c *Collection) tenantModels(ctx context.Context, models []mongo.WriteModel) ([]mongo.WriteModel, error) {
+	tenant, enabled, err := c.tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled || c.Database.Client.tenant.Field == "" {
+		return models, nil
+	}
+
+	field := c.Database.Client.tenant.Field
+	newModels := make([]mongo.WriteModel, len(models))
+	for i, model := range models {
+		switch m := model.(type) {
+		case *mongo.InsertOneModel:
+			doc, err := c.stampTenantDoc(ctx, m.Document)
+			if err != nil {
+				return nil, err
+			}
+			newModel := *m
+			newModel.Document = doc
+			newModels[i] = &newModel
+		case *mongo.UpdateOneModel:
+			newModel := *m
+			newModel.Filter = tenantCond(m.Filter, field, tenant)
+			newModels[i] = &newModel
+		case *mongo.UpdateManyModel:
+			newModel := *m
+			newModel.Filter = tenantCond(m.Filter, field, tenant)
+			newModels[i] = &newModel
+		case *mongo.ReplaceOneModel:
+			doc, err := c.stampTenantDoc(ctx, m.Replacement)
+			if err != nil {
+				return nil, err
+			}
+			newModel := *m
+			newModel.Filter = tenantCond(m.Filter, field, tenant)
+			newModel.Replacement = doc
+			newModels[i] = &newModel
+		case *mongo.DeleteOneModel:
+			newModel := *m
+			newModel.Filter = tenantCond(m.Filter, field, tenant)
+			newModels[i] = &newModel
+		case *mongo.DeleteManyModel:
+			newModel := *m
+			newModel.Filter = tenantCond(m.Filter, field, tenant)
+			newModels[i] = &newModel
+		default:
+			return nil, fmt.Errorf("[tenant]: unsupported write model %T", model)
+		}
+	}
+	return newModels, nil
+}
+
+// tenantPipeline 聚合管道最前面追加租户 $match
+func (c *Collection) tenantPipeline(ctx context.Context, pipeline []interface{}) ([]interface{}, error) {
+	tenant, enabled, err := c.tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled || c.Database.Client.tenant.Field == "" {
+		return pipeline, nil
+	}
+
+	// $geoNear、$search 等必须是第一个阶段，租户条件放在其后
+	idx := 0
+	if len(pipeline) > 0 && firstStages[stageName(pipeline[0])] {
+		idx = 1
+	}
+	newPipeline := make([]interface{}, 0, len(pipeline)+1)
+	newPipeline = append(newPipeline, pipeline[:idx]...)
+	newPipeline = append(newPipeline, map[string]interface{}{
+		"$match": map[string]interface{}{c.Database.Client.tenant.Field: tenant},
+	})
+	return append(newPipeline, pipeline[idx:]...), nil
+}
+
+// firstStages 必须作为管道第一个阶段的操作
+var firstStages = map[string]bool{
+	"$geoNear":      true,
+	"$search":       true,
+	"$searchMeta":   true,
+	"$vectorSearch": true,
+}
+
+// stageName 管道阶段的操作名，无法识别时返回空
+func stageName(stage interface{}) string {
+	switch s := stage.(type) {
+	case map[string]interface{}:
+		if len(s) == 1 {
+			for k := range s {
+				return k
+			}
+		}
+	case bson.M:
+		if len(s) == 1 {
+			for k := range s {
+				return k
+			}
+		}
+	case bson.D:
+		if len(s) == 1 {
+			return s[0].Key
+		}
+	}
+	return ""
+}
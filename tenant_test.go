@@ -0,0 +1,138 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func newTenantCollection(name string) *Collection {
+	cli := &Client{ctx: context.Background()}
+	cli.SetTenant(&TenantConf{Field: "tenant_id", Ignore: []string{"global"}})
+	db := &Database{Client: cli, ctx: cli.ctx}
+	return &Collection{Database: db, ctx: db.ctx, collectionName: name}
+}
+
+func TestTenantCond(t *testing.T) {
+	c := newTenantCollection("test")
+
+	if _, err := c.cond(context.Background(), Q("txt", "1")); !errors.Is(err, ErrTenantRequired) {
+		t.Fatalf("expect ErrTenantRequired, got %v", err)
+	}
+
+	ctx := WithTenant(context.Background(), "t1")
+	cond, err := c.cond(ctx, Q("txt", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cond["tenant_id"] != "t1" || len(cond) != 2 {
+		t.Fatalf("tenant condition error: %v", cond)
+	}
+
+	cond, err = c.cond(ctx, Q("tenant_id", "t2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cond["$and"]; !ok {
+		t.Fatalf("tenant condition must be and with user condition: %v", cond)
+	}
+
+	cond, err = newTenantCollection("global").cond(context.Background(), Q("txt", "1"))
+	if err != nil || len(cond) != 1 {
+		t.Fatalf("ignored collection error: %v, %v", cond, err)
+	}
+
+	cond, err = c.cond(IgnoreTenant(context.Background()), Q("txt", "1"))
+	if err != nil || len(cond) != 1 {
+		t.Fatalf("ignore tenant ctx error: %v, %v", cond, err)
+	}
+}
+
+func TestTenantStamp(t *testing.T) {
+	c := newTenantCollection("test")
+	ctx := WithTenant(context.Background(), "t1")
+
+	raw := map[string]interface{}{"txt": "1"}
+	doc, err := c.stampTenant(ctx, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["tenant_id"] != "t1" {
+		t.Fatalf("tenant field not stamped: %v", doc)
+	}
+	if _, ok := raw["tenant_id"]; ok {
+		t.Fatalf("input doc changed: %v", raw)
+	}
+
+	_, err = c.stampTenant(ctx, map[string]interface{}{"tenant_id": "t2"})
+	if !errors.Is(err, ErrTenantMismatch) {
+		t.Fatalf("expect ErrTenantMismatch, got %v", err)
+	}
+
+	bwm := NewBulkWriteModel().
+		AddInsertOneModel(map[string]interface{}{"txt": "1"}).
+		AddDeleteOneModel(Q("txt", "1"))
+	models, err := c.tenantModels(ctx, bwm.models)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if models[0].(*mongo.InsertOneModel).Document.(map[string]interface{})["tenant_id"] != "t1" {
+		t.Fatalf("insert model not stamped: %v", models[0])
+	}
+	if models[1].(*mongo.DeleteOneModel).Filter.(map[string]interface{})["tenant_id"] != "t1" {
+		t.Fatalf("delete model filter error: %v", models[1])
+	}
+	if _, ok := bwm.models[1].(*mongo.DeleteOneModel).Filter.(map[string]interface{})["tenant_id"]; ok {
+		t.Fatal("bulk write model changed")
+	}
+}
+
+func TestTenantPipeline(t *testing.T) {
+	c := newTenantCollection("test")
+	ctx := WithTenant(context.Background(), "t1")
+
+	pipeline, err := c.tenantPipeline(ctx, []interface{}{map[string]interface{}{"$sort": map[string]interface{}{"a": 1}}})
+	if err != nil || len(pipeline) != 2 || stageName(pipeline[0]) != "$match" {
+		t.Fatalf("match must be the first stage: %v, %v", pipeline, err)
+	}
+
+	geoNear := bson.D{{Key: "$geoNear", Value: bson.M{"near": []float64{0, 0}}}}
+	pipeline, err = c.tenantPipeline(ctx, []interface{}{geoNear, map[string]interface{}{"$limit": 1}})
+	if err != nil || len(pipeline) != 3 || stageName(pipeline[0]) != "$geoNear" || stageName(pipeline[1]) != "$match" {
+		t.Fatalf("match must follow $geoNear: %v, %v", pipeline, err)
+	}
+}
+
+func TestTenantDatabaseRequired(t *testing.T) {
+	cli, err := NewClient(context.Background(), OptionsFromURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close(context.Background())
+	cli.SetTenant(&TenantConf{Database: func(dbName string, tenant interface{}) string {
+		return fmt.Sprintf("%s_%v", dbName, tenant)
+	}})
+
+	orm := NewORMByClient(context.Background(), cli, "test", "tb", nil)
+	if _, err = orm.Count(false); !errors.Is(err, ErrTenantRequired) {
+		t.Fatalf("expect ErrTenantRequired, got %v", err)
+	}
+	// 访问器不 panic，集合与数据库的操作返回路由错误
+	if !errors.Is(orm.Err(), ErrTenantRequired) {
+		t.Fatalf("expect ErrTenantRequired, got %v", orm.Err())
+	}
+	if _, err = orm.Collection().Count(context.Background(), NewQuery(), nil); !errors.Is(err, ErrTenantRequired) {
+		t.Fatalf("collection must return ErrTenantRequired, got %v", err)
+	}
+	if _, _, err = orm.Database().TryCollection("tb"); !errors.Is(err, ErrTenantRequired) {
+		t.Fatalf("database must return ErrTenantRequired, got %v", err)
+	}
+	orm = NewORMByClient(WithTenant(context.Background(), "t1"), cli, "test", "tb", nil)
+	if orm.err != nil || orm.db.dbName != "test_t1" {
+		t.Fatalf("tenant database error: %v, %v", orm.db.dbName, orm.err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package mongo
+package mongo
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UpsertOutcome UpsertBy 单个文档的结果
+type UpsertOutcome struct {
+	// Index 文档在数据中的下标，BulkWriteModel 中为操作的下标
+	Index int
+	// Inserted true 为插入，false 为更新
+	Inserted bool
+	// ID 插入的主键，ObjectID 为 hex 字符串，更新时为 nil
+	ID interface{}
+}
+
+// upsertByOps 按业务主键拆分文档：keys 字段为条件，_id 与 insertOnly 字段只在插入时写入（$setOnInsert），其他字段为 $set
+func upsertByOps(keys []string, m map[string]interface{}, insertOnly []string) (Where, UpdateOps, error) {
+	if len(keys) <= 0 {
+		return nil, nil, fmt.Errorf("%w: upsert keys is empty", ErrInvalidQuery)
+	}
+
+	where := Where{}
+	for _, k := range keys {
+		v, ok := m[k]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: upsert key [%s] is not in document", ErrInvalidQuery, k)
+		}
+		where[k] = v
+	}
+
+	onInsert := map[string]interface{}{}
+	if id, ok := m["_id"]; ok {
+		if _, isKey := where["_id"]; !isKey {
+			onInsert["_id"] = id
+		}
+	}
+	for _, k := range insertOnly {
+		if _, isKey := where[k]; isKey {
+			continue
+		}
+		if v, ok := m[k]; ok {
+			onInsert[k] = v
+		}
+	}
+
+	set := map[string]interface{}{}
+	for k, v := range m {
+		if _, ok := where[k]; ok {
+			continue
+		}
+		if _, ok := onInsert[k]; ok {
+			continue
+		}
+		set[k] = v
+	}
+
+	// 文档只有条件字段时，插入时写入条件字段
+	if len(set) <= 0 && len(onInsert) <= 0 {
+		for k, v := range where {
+			onInsert[k] = v
+		}
+	}
+	return where, UpdateOps{UpdateSet: set, UpdateSetOnInsert: onInsert}, nil
+}
+
+// toUpsertDoc map 复制一份，struct 转换为 map
+func toUpsertDoc(doc interface{}) map[string]interface{} {
+	switch doc := doc.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			m[k] = v
+		}
+		return m
+	default:
+		return Struct2Map(doc)
+	}
+}
+
+// AddUpsertBy 按业务主键 keys 更新或插入文档，doc 为 map 或 struct
+// _id 与 insertOnly 字段（如：created_at）只在插入时写入，其他字段更新；BulkWriteResult.Upserts 返回每个文档是插入还是更新
+// 设置了 SetKeyType 时按主键类型处理 _id，未设置时只删除空字符串 _id，其他 _id 原样写入
+// keys 不在文档中时 panic
+func (bwm *BulkWriteModel) AddUpsertBy(keys []string, doc interface{}, insertOnly ...string) *BulkWriteModel {
+	m := toUpsertDoc(doc)
+	if bwm.keyType != nil {
+		if err := bwm.keyType.formatInsertID(m); err != nil {
+			panic(err)
+		}
+	} else if id, ok := m["_id"]; ok && id == "" {
+		delete(m, "_id")
+	}
+	where, ops, err := upsertByOps(keys, m, insertOnly)
+	if err != nil {
+		panic(err)
+	}
+	return bwm.addUpsert(MixQ(where), ops)
+}
+
+func (bwm *BulkWriteModel) addUpsert(filter *Query, ops UpdateOps) *BulkWriteModel {
+	upModel := mongo.NewUpdateOneModel().SetFilter(filter.Cond()).SetUpdate(ops.document()).SetUpsert(true)
+	bwm.upserts = append(bwm.upserts, len(bwm.models))
+	bwm.models = append(bwm.models, upModel)
+	return bwm
+}
+
+// upsertOutcomes AddUpsertBy 添加的操作的结果，upsertedIDs 中没有的操作为更新
+func (bwm *BulkWriteModel) upsertOutcomes(upsertedIDs map[int64]interface{}) []UpsertOutcome {
+	if len(bwm.upserts) <= 0 {
+		return nil
+	}
+	ret := make([]UpsertOutcome, 0, len(bwm.upserts))
+	for _, idx := range bwm.upserts {
+		outcome := UpsertOutcome{Index: idx}
+		if id, ok := upsertedIDs[int64(idx)]; ok {
+			outcome.Inserted = true
+			outcome.ID = insertedID(id)
+		}
+		ret = append(ret, outcome)
+	}
+	return ret
+}
+
+// UpsertBy 按业务主键 keys 更新或插入，data 为 map、struct 或它们的数组，数组使用 BulkWrite
+// 条件只使用 keys 与表的默认作用域；_id 与 insertOnly 字段（如：created_at）只在插入时写入，其他字段更新
+// 非 ObjectID 主键的表插入时需要 _id（KeyUUID 自动生成），返回每个文档是插入还是更新
+func (orm *ORM) UpsertBy(keys []string, data interface{}, insertOnly ...string) (ret []UpsertOutcome, err error) {
+	op := orm.observe("UpsertBy", &err)
+	defer op.end()
+	if data == nil {
+		return nil, fmt.Errorf("%w: upsert data is nil", ErrInvalidQuery)
+	}
+
+	table, err := orm.table()
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []interface{}
+	dataValue := reflect.ValueOf(data)
+	isList := dataValue.Kind() == reflect.Slice || dataValue.Kind() == reflect.Array
+	if isList {
+		for i := 0; i < dataValue.Len(); i++ {
+			docs = append(docs, dataValue.Index(i).Interface())
+		}
+		if len(docs) <= 0 {
+			return nil, fmt.Errorf("%w: upsert data is empty", ErrInvalidQuery)
+		}
+	} else {
+		docs = append(docs, data)
+	}
+
+	kt := orm.refConf.getPrimaryKey(orm.tableName)
+	bwm := NewBulkWriteModel()
+	var one *UpdateOps
+	var oneQ *Query
+	for _, doc := range docs {
+		m := toUpsertDoc(doc)
+		if err = kt.formatInsertID(m); err != nil {
+			return nil, err
+		}
+		where, ops, e := upsertByOps(keys, m, insertOnly)
+		if e != nil {
+			return nil, e
+		}
+
+		q := MixQ(orm.formatWhere(orm.tableName, orm.scopedWhere(orm.tableName, where)))
+		if !isList {
+			one, oneQ = &ops, q
+			op.q = q
+			break
+		}
+		bwm.addUpsert(q, ops)
+	}
+
+	if one != nil {
+		opt := NewUpdate()
+		opt.Upsert(true)
+		r, err := table.UpdateOneOps(op.ctx, oneQ, *one, opt)
+		if err != nil {
+			return nil, err
+		}
+		outcome := UpsertOutcome{Inserted: r.UpsertedCount > 0}
+		if outcome.Inserted {
+			outcome.ID = insertedID(r.UpsertedID)
+		}
+		return []UpsertOutcome{outcome}, nil
+	}
+
+	r, err := table.BulkWrite(op.ctx, bwm)
+	if err != nil {
+		return nil, err
+	}
+	return r.Upserts, nil
+}
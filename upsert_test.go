@@ -0,0 +1,93 @@
+package mongo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type upsertModel struct {
+	ID        string    `bson:"_id"`
+	Code      string    `bson:"code"`
+	Name      string    `bson:"name"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+func TestUpsertByOps(t *testing.T) {
+	now := time.Now()
+	m := toUpsertDoc(upsertModel{Code: "c1", Name: "a", CreatedAt: now})
+	if err := KeyObjectID.formatInsertID(m); err != nil {
+		t.Fatal(err)
+	}
+	where, ops, err := upsertByOps([]string{"code"}, m, []string{"created_at"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(where, Where{"code": "c1"}) {
+		t.Fatal("where", where)
+	}
+	if !reflect.DeepEqual(ops[UpdateSet], map[string]interface{}{"name": "a"}) {
+		t.Fatal("set", ops[UpdateSet])
+	}
+	if !reflect.DeepEqual(ops[UpdateSetOnInsert], map[string]interface{}{"created_at": now}) {
+		t.Fatal("setOnInsert", ops[UpdateSetOnInsert])
+	}
+
+	id := NewObjectID()
+	_, ops, err = upsertByOps([]string{"code"}, map[string]interface{}{"_id": id, "code": "c1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops[UpdateSet]) != 0 || ops[UpdateSetOnInsert]["_id"] != id {
+		t.Fatal("_id must be set on insert", ops)
+	}
+
+	_, ops, err = upsertByOps([]string{"code"}, map[string]interface{}{"code": "c1"}, nil)
+	if err != nil || ops[UpdateSetOnInsert]["code"] != "c1" {
+		t.Fatal("keys only document", ops, err)
+	}
+
+	if _, _, err = upsertByOps([]string{"uid"}, m, nil); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatal("missing key must be ErrInvalidQuery", err)
+	}
+	if _, _, err = upsertByOps(nil, m, nil); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatal("empty keys must be ErrInvalidQuery", err)
+	}
+}
+
+func TestBulkUpsertOutcomes(t *testing.T) {
+	bwm := NewBulkWriteModel().
+		AddDeleteOneModel(MixQ(Where{"code": "c0"})).
+		AddUpsertBy([]string{"code"}, map[string]interface{}{"code": "c1", "name": "a"}).
+		AddUpsertBy([]string{"code"}, upsertModel{Code: "c2", Name: "b"}, "created_at")
+	id := NewObjectID()
+	ret := bwm.upsertOutcomes(map[int64]interface{}{2: id})
+	want := []UpsertOutcome{{Index: 1}, {Index: 2, Inserted: true, ID: id.Hex()}}
+	if !reflect.DeepEqual(ret, want) {
+		t.Fatal(ret)
+	}
+
+	insertID := func(bwm *BulkWriteModel) interface{} {
+		update := bwm.models[len(bwm.models)-1].(*mongo.UpdateOneModel).Update.(bson.M)
+		return update["$setOnInsert"].(map[string]interface{})["_id"]
+	}
+	if id := insertID(NewBulkWriteModel().AddUpsertBy([]string{"code"}, map[string]interface{}{"_id": "sku-1", "code": "c1"})); id != "sku-1" {
+		t.Fatal("string _id must be kept", id)
+	}
+	objBwm := NewBulkWriteModel().SetKeyType(KeyObjectID).
+		AddUpsertBy([]string{"code"}, map[string]interface{}{"_id": "62788a8e92961d9287c6b8d2", "code": "c1"})
+	if _, ok := insertID(objBwm).(ObjectID); !ok {
+		t.Fatal("object id key must be converted")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("missing key must panic")
+		}
+	}()
+	bwm.AddUpsertBy([]string{"uid"}, map[string]interface{}{"code": "c3"})
+}
@@ -2,54 +2,219 @@
 package mongo
 
 import (
+	"fmt"
+	"math"
 	"reflect"
 
 	"github.com/assembly-hub/basics/set"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
 )
 
+// Struct2MapOptions Struct2MapWithOptions 的配置
+type Struct2MapOptions struct {
+	// ExcludeKeys 排除的字段名（顶层，inline 字段同样为顶层）
+	ExcludeKeys []string
+	// OmitZero 排除所有零值字段，相当于每个字段都设置了 omitempty
+	OmitZero bool
+}
+
+// Struct2Map struct 转 map，与driver编码的文档一致
+// 标签：字段名（缺省为小写的字段名）、"-"、omitempty、minsize、inline（结构体、结构体指针、map）
+// 匿名结构体字段没有 inline 时与driver一致，作为子文档；未导出字段忽略；_id 为空字符串、零值时忽略
+// 嵌套的结构体不转换，由driver按其标签编码
 func Struct2Map(raw interface{}, excludeKey ...string) map[string]interface{} {
+	return Struct2MapWithOptions(raw, &Struct2MapOptions{ExcludeKeys: excludeKey})
+}
+
+// Struct2MapWithOptions 同 Struct2Map，opts 可以为 nil
+func Struct2MapWithOptions(raw interface{}, opts *Struct2MapOptions) map[string]interface{} {
 	dataValue := reflect.ValueOf(raw)
-	if dataValue.Type().Kind() != reflect.Struct && dataValue.Type().Kind() != reflect.Ptr {
+	if dataValue.Kind() != reflect.Struct && dataValue.Kind() != reflect.Ptr {
 		panic("data type must be struct or struct ptr")
 	}
 
-	if dataValue.Type().Kind() == reflect.Ptr {
+	if dataValue.Kind() == reflect.Ptr {
 		dataValue = dataValue.Elem()
 	}
 
-	if dataValue.Type().Kind() != reflect.Struct {
+	if dataValue.Kind() != reflect.Struct {
 		panic("data type must be struct or struct ptr")
 	}
 
+	if opts == nil {
+		opts = &Struct2MapOptions{}
+	}
+	m := struct2Map(dataValue, opts.OmitZero)
+
 	s := set.Set[string]{}
-	s.Add(excludeKey...)
+	s.Add(opts.ExcludeKeys...)
+	for k := range m {
+		if s.Has(k) {
+			delete(m, k)
+		}
+	}
+	return m
+}
+
+func struct2Map(dataValue reflect.Value, omitZero bool) map[string]interface{} {
+	m, _ := structFields(dataValue, omitZero)
+	return m
+}
+
+// structFields 返回字段与所有字段名（包括被忽略的零值字段），inline map 的键与字段名冲突时 panic
+func structFields(dataValue reflect.Value, omitZero bool) (map[string]interface{}, map[string]bool) {
+	tp := dataValue.Type()
 	m := map[string]interface{}{}
-	for i := 0; i < dataValue.NumField(); i++ {
-		colName := dataValue.Type().Field(i).Tag.Get("bson")
-		if colName == "" || !dataValue.Type().Field(i).IsExported() {
+	names := map[string]bool{}
+	var inlines, inlineMaps []map[string]interface{}
+	for i := 0; i < tp.NumField(); i++ {
+		sf := tp.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		tags, err := bsoncodec.DefaultStructTagParser.ParseStructTags(sf)
+		if err != nil {
+			panic(fmt.Sprintf("struct [%s] field [%s] tag error: %v", tp.Name(), sf.Name, err))
+		}
+		if tags.Skip {
+			continue
+		}
+
+		field := dataValue.Field(i)
+		if tags.Inline {
+			inline, inlineNames := inlineFields(tp, sf, field, omitZero)
+			if inlineNames == nil {
+				inlineMaps = append(inlineMaps, inline)
+				continue
+			}
+			inlines = append(inlines, inline)
+			for k := range inlineNames {
+				names[k] = true
+			}
+			continue
+		}
+
+		names[tags.Name] = true
+		if (tags.OmitEmpty || omitZero) && isZero(field) {
+			continue
+		}
+		if tags.Name == "_id" && isEmptyID(field) {
 			continue
 		}
 
-		if colName == "_id" {
-			if dataValue.Field(i).Kind() == reflect.String && dataValue.Field(i).String() == "" {
+		if tags.MinSize {
+			if v, ok := minSize(field); ok {
+				m[tags.Name] = v
 				continue
 			}
+		}
+		m[tags.Name] = field.Interface()
+	}
 
-			if dataValue.Field(i).Kind() == reflect.Array {
-				var objID ObjectID
-				b := dataValue.Field(i).Bytes()
-				copy(objID[:], b)
-				if objID.IsZero() {
-					continue
-				}
+	// 外层字段优先，与driver一致
+	for _, inline := range inlines {
+		for k, v := range inline {
+			if _, ok := m[k]; !ok {
+				m[k] = v
 			}
 		}
+	}
+	for _, inline := range inlineMaps {
+		for k, v := range inline {
+			if names[k] {
+				panic(fmt.Sprintf("struct [%s] inline map key [%s] conflicts with a struct field name", tp.Name(), k))
+			}
+			m[k] = v
+		}
+	}
+	return m, names
+}
 
-		if !s.Has(colName) {
-			m[colName] = dataValue.Field(i).Interface()
+// inlineFields inline 字段转 map，nil 指针为空，map 返回的字段名为 nil
+func inlineFields(tp reflect.Type, sf reflect.StructField, field reflect.Value, omitZero bool) (map[string]interface{}, map[string]bool) {
+	switch field.Kind() {
+	case reflect.Ptr:
+		if field.Type().Elem().Kind() != reflect.Struct {
+			break
+		}
+		if field.IsNil() {
+			return nil, map[string]bool{}
 		}
+		return structFields(field.Elem(), omitZero)
+	case reflect.Struct:
+		return structFields(field, omitZero)
+	case reflect.Map:
+		if field.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := make(map[string]interface{}, field.Len())
+		iter := field.MapRange()
+		for iter.Next() {
+			if omitZero && isZero(iter.Value()) {
+				continue
+			}
+			m[iter.Key().String()] = iter.Value().Interface()
+		}
+		return m, nil
 	}
-	return m
+	panic(fmt.Sprintf("struct [%s] inline field [%s] must be struct, struct ptr or map[string]T", tp.Name(), sf.Name))
+}
+
+// isZero 与driver omitempty 的判断一致：实现 IsZero() bool 的类型按其结果，结构体不为空
+func isZero(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	if z, ok := v.Interface().(bsoncodec.Zeroer); ok && (v.Kind() != reflect.Ptr || !v.IsNil()) {
+		return z.IsZero()
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isEmptyID _id 为空字符串、nil、零值 ObjectID/UUID 时由插入逻辑生成
+func isEmptyID(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return v.Len() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Array:
+		if z, ok := v.Interface().(bsoncodec.Zeroer); ok {
+			return z.IsZero()
+		}
+		return v.IsZero()
+	}
+	return false
+}
+
+// minSize 与driver一致：可以用 int32 表示的整数转换为 int32
+func minSize(v reflect.Value) (interface{}, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		if i := v.Int(); i >= math.MinInt32 && i <= math.MaxInt32 {
+			return int32(i), true
+		}
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		if u := v.Uint(); u <= math.MaxInt32 {
+			return int32(u), true
+		}
+	}
+	return nil, false
 }
 
 func TransSession(cli *Client, fn func(sessionCtx SessionContext) error) error {
@@ -0,0 +1,82 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type mapBase struct {
+	CreatedAt time.Time `bson:"created_at,omitempty"`
+	Name      string    `bson:"base_name"`
+}
+
+type mapExtra struct {
+	Tag string `bson:"tag"`
+}
+
+type mapDoc struct {
+	ID      ObjectID `bson:"_id,omitempty"`
+	Name    string   `bson:"name,omitempty"`
+	Age     int64    `bson:"age,minsize"`
+	Skip    string   `bson:"-"`
+	NoTag   string
+	Base    mapBase                `bson:",inline"`
+	Extra   *mapExtra              `bson:",inline"`
+	Attrs   map[string]interface{} `bson:",inline"`
+	Sub     mapExtra               `bson:"sub"`
+	Comment *string                `bson:"comment,omitempty"`
+	private string
+}
+
+func TestStruct2Map(t *testing.T) {
+	doc := mapDoc{
+		Age:   18,
+		Skip:  "skip",
+		NoTag: "no tag",
+		Base:  mapBase{Name: "base"},
+		Extra: &mapExtra{Tag: "t1"},
+		Attrs: map[string]interface{}{"color": "red", "size": "xl"},
+		Sub:   mapExtra{Tag: "sub"},
+	}
+
+	m := Struct2Map(&doc, "color")
+	want := map[string]interface{}{
+		"age":       int32(18),
+		"notag":     "no tag",
+		"base_name": "base",
+		"tag":       "t1",
+		"size":      "xl",
+		"sub":       mapExtra{Tag: "sub"},
+	}
+	if len(m) != len(want) {
+		t.Fatalf("struct to map error: %v", m)
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Fatalf("key [%s] want %v, got %v", k, v, m[k])
+		}
+	}
+
+	// 与driver编码结果一致
+	raw, _ := bson.Marshal(doc)
+	var driverDoc map[string]interface{}
+	_ = bson.Unmarshal(raw, &driverDoc)
+	delete(driverDoc, "color")
+	if len(driverDoc) != len(m) {
+		t.Fatalf("driver document: %v, struct map: %v", driverDoc, m)
+	}
+
+	m = Struct2MapWithOptions(mapDoc{Age: 1}, &Struct2MapOptions{OmitZero: true})
+	if len(m) != 2 || m["age"] != int32(1) {
+		t.Fatalf("omit zero error: %v", m)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("inline map key conflicts must panic")
+		}
+	}()
+	Struct2Map(mapDoc{Attrs: map[string]interface{}{"name": "conflict"}})
+}
@@ -0,0 +1,153 @@
+// Package mongo
+package mongo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	bsonSubtypeUUIDOld = 0x03
+	bsonSubtypeUUID    = 0x04
+)
+
+// UUID 保存为 Binary subtype 4，字符串、JSON 格式：xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+type UUID [16]byte
+
+var tUUID = reflect.TypeOf(UUID{})
+
+// NewUUID 生成随机 UUID（v4）
+func NewUUID() UUID {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(err)
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+// String2UUID string 转 UUID，支持带或不带 "-" 的格式
+func String2UUID(v string) (u UUID, err error) {
+	b, err := hex.DecodeString(strings.ReplaceAll(v, "-", ""))
+	if err != nil {
+		return UUID{}, fmt.Errorf("uuid [%s] is invalid: %w", v, err)
+	}
+	if len(b) != len(u) {
+		return UUID{}, fmt.Errorf("uuid [%s] is invalid", v)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+// TryString2UUID string 转 UUID，格式错误时 panic
+func TryString2UUID(v string) UUID {
+	u, err := String2UUID(v)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// binaryUUID Binary subtype 3、4 转 UUID
+func binaryUUID(b primitive.Binary) (UUID, bool) {
+	var u UUID
+	if (b.Subtype != bsonSubtypeUUID && b.Subtype != bsonSubtypeUUIDOld) || len(b.Data) != len(u) {
+		return u, false
+	}
+	copy(u[:], b.Data)
+	return u, true
+}
+
+func (u UUID) String() string {
+	b := make([]byte, 36)
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b)
+}
+
+// IsZero 是否为空 UUID
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+
+// MarshalText 实现 encoding.TextMarshaler，JSON 序列化为字符串
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText 实现 encoding.TextUnmarshaler，空字符串为空 UUID
+func (u *UUID) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		*u = UUID{}
+		return nil
+	}
+	v, err := String2UUID(string(b))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+func uuidEncodeValue(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if !val.IsValid() || val.Type() != tUUID {
+		return bsoncodec.ValueEncoderError{Name: "UUIDEncodeValue", Types: []reflect.Type{tUUID}, Received: val}
+	}
+	u := val.Interface().(UUID)
+	return vw.WriteBinaryWithSubtype(u[:], bsonSubtypeUUID)
+}
+
+func uuidDecodeValue(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	if !val.CanSet() || val.Type() != tUUID {
+		return bsoncodec.ValueDecoderError{Name: "UUIDDecodeValue", Types: []reflect.Type{tUUID}, Received: val}
+	}
+
+	var u UUID
+	switch vr.Type() {
+	case bsontype.Binary:
+		data, subtype, err := vr.ReadBinary()
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if u, ok = binaryUUID(primitive.Binary{Subtype: subtype, Data: data}); !ok {
+			return fmt.Errorf("cannot decode binary subtype %d length %d into a UUID", subtype, len(data))
+		}
+	case bsontype.String:
+		s, err := vr.ReadString()
+		if err != nil {
+			return err
+		}
+		if err = u.UnmarshalText([]byte(s)); err != nil {
+			return err
+		}
+	case bsontype.Null:
+		if err := vr.ReadNull(); err != nil {
+			return err
+		}
+	case bsontype.Undefined:
+		if err := vr.ReadUndefined(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot decode %v into a UUID", vr.Type())
+	}
+	val.Set(reflect.ValueOf(u))
+	return nil
+}
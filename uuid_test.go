@@ -0,0 +1,97 @@
+package mongo
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type uuidDoc struct {
+	ID    UUID    `bson:"_id" json:"id"`
+	Price Decimal `bson:"price" json:"price"`
+}
+
+func TestUUID(t *testing.T) {
+	u := NewUUID()
+	if u.IsZero() || u[6]>>4 != 4 {
+		t.Fatalf("uuid version error: %s", u)
+	}
+	v, err := String2UUID(u.String())
+	if err != nil || v != u {
+		t.Fatalf("uuid string round trip error: %s, %v", v, err)
+	}
+	if _, err = String2UUID("123"); err == nil {
+		t.Fatal("invalid uuid must return error")
+	}
+
+	b, _ := json.Marshal(uuidDoc{ID: u, Price: TryString2Decimal("12.30")})
+	var doc uuidDoc
+	if err = json.Unmarshal(b, &doc); err != nil || doc.ID != u || doc.Price.String() != "12.30" {
+		t.Fatalf("json round trip error: %s, %v", b, err)
+	}
+	if err = json.Unmarshal([]byte(`{"price": 1.5}`), &doc); err != nil || doc.Price.String() != "1.5" {
+		t.Fatalf("decimal json number error: %v, %v", doc.Price, err)
+	}
+}
+
+func TestUUIDDecimalCodec(t *testing.T) {
+	registry := register(nil)
+	u := NewUUID()
+	raw, err := bson.MarshalWithRegistry(registry, uuidDoc{ID: u, Price: TryString2Decimal("99.99")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]interface{}
+	if err = bson.UnmarshalWithRegistry(registry, raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := m["_id"].(primitive.Binary); !ok || id.Subtype != bsonSubtypeUUID {
+		t.Fatalf("uuid must be binary subtype 4: %#v", m["_id"])
+	}
+	if _, ok := m["price"].(primitive.Decimal128); !ok {
+		t.Fatalf("decimal must be decimal128: %#v", m["price"])
+	}
+
+	var doc uuidDoc
+	if err = bson.UnmarshalWithRegistry(registry, raw, &doc); err != nil || doc.ID != u || doc.Price.String() != "99.99" {
+		t.Fatalf("decode error: %+v, %v", doc, err)
+	}
+
+	raw, _ = bson.Marshal(bson.M{"_id": u.String(), "price": 12.5})
+	if err = bson.UnmarshalWithRegistry(registry, raw, &doc); err != nil || doc.ID != u || doc.Price.String() != "12.5" {
+		t.Fatalf("decode from string and double error: %+v, %v", doc, err)
+	}
+
+	orm := &ORM{}
+	var s string
+	if err = orm.setDataFunc(reflect.ValueOf(&s).Elem(), m["_id"]); err != nil || s != u.String() {
+		t.Fatalf("set uuid string error: %s, %v", s, err)
+	}
+	var bin primitive.Binary
+	if err = orm.setDataFunc(reflect.ValueOf(&bin).Elem(), m["_id"]); err != nil || bin.Subtype != bsonSubtypeUUID || !bytes.Equal(bin.Data, u[:]) {
+		t.Fatalf("set binary error: %v, %v", bin, err)
+	}
+	var val interface{}
+	if err = orm.setDataFunc(reflect.ValueOf(&val).Elem(), m["_id"]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := val.(primitive.Binary); !ok {
+		t.Fatalf("set interface must keep binary: %T", val)
+	}
+	var f float64
+	if err = orm.setDataFunc(reflect.ValueOf(&f).Elem(), m["price"]); err != nil || f != 99.99 {
+		t.Fatalf("set decimal float error: %v, %v", f, err)
+	}
+	var d Decimal
+	if err = orm.setDataFunc(reflect.ValueOf(&d).Elem(), m["price"]); err != nil || d.String() != "99.99" {
+		t.Fatalf("set decimal error: %v, %v", d, err)
+	}
+	if isDocument(reflect.TypeOf(d)) || !isDocument(reflect.TypeOf(doc)) {
+		t.Fatal("decimal must be decoded as single field")
+	}
+}